@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"greenlight.wolfheros.com/internal/data"
+)
+
+// Define a custom [contextKey] type, to avoid the key collision with
+// other packages which also store data in the request context
+type contextKey string
+
+// [userContextKey] is the key for storing the user information in the request context,
+// [requestInfoContextKey] is the key for storing the [requestInfo] of the request,
+// [requestIDContextKey] is the key for storing the request ID
+const (
+	userContextKey        = contextKey("user")
+	requestInfoContextKey = contextKey("requestInfo")
+	requestIDContextKey   = contextKey("requestID")
+)
+
+// [contextSetUser()] return a new copy of the request with the [User] struct added to the context
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	// Record the user ID for the access log
+	if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+		info.userID = user.ID
+	}
+
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// [contextGetUser()] retrieve the [User] struct from the request context.
+// The [authenticate()] middleware always set a user, so if it doesn't exist,
+// it is an unexpected error and we panic.
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+
+	return user
+}
+
+// [requestInfo] hold the information which is only known deep inside the middleware
+// chain, but needed by the outer middleware once the request has been served:
+// the URL pattern of the matched route (such as "/v1/movies/:id") and the user ID.
+// The outermost middleware put an empty one in the context before routing, the
+// [recordRoute()] wrapper and [contextSetUser()] fill it in.
+type requestInfo struct {
+	route  string
+	userID int64
+}
+
+// [contextRequestInfo()] return the [requestInfo] of the request, if there is none yet,
+// return a new copy of the request with an empty [requestInfo] added to the context
+func (app *application) contextRequestInfo(r *http.Request) (*http.Request, *requestInfo) {
+	if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+		return r, info
+	}
+
+	info := &requestInfo{}
+	ctx := context.WithValue(r.Context(), requestInfoContextKey, info)
+	return r.WithContext(ctx), info
+}
+
+// [recordRoute()] wrap a route handler, record its URL pattern in the [requestInfo]
+func (app *application) recordRoute(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+			info.route = pattern
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// [contextSetRequestID()] return a new copy of the request with the request ID added to the context
+func (app *application) contextSetRequestID(r *http.Request, requestID string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+	return r.WithContext(ctx)
+}
+
+// [contextGetRequestID()] retrieve the request ID from the request context,
+// return an empty string if the [requestID()] middleware didn't run
+func (app *application) contextGetRequestID(r *http.Request) string {
+	requestID, _ := r.Context().Value(requestIDContextKey).(string)
+	return requestID
+}
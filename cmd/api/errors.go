@@ -1,61 +1,188 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"greenlight.wolfheros.com/internal/i18n"
+	"greenlight.wolfheros.com/internal/validator"
 )
 
 // log any error happend on the server
 func (app *application) logError(r *http.Request, err error) {
 	var (
-		method = r.Method
-		uri    = r.URL.RequestURI()
+		method    = r.Method
+		uri       = r.URL.RequestURI()
+		requestID = app.contextGetRequestID(r)
 	)
 
-	app.logger.Error(err.Error(), "method", method, "uri", uri)
+	app.logger.Error(err.Error(), "request_id", requestID, "method", method, "uri", uri)
 
 
 }
 
-// Response with JSON result
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any){
-	env := envelope{"error":message}
+// Response with JSON result, all the error helpers go through it.
+// The default format is {"error": message}, if the client ask for it (or it is enabled
+// by the [-error-format] flag), the RFC 9457 [application/problem+json] format is used.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, problem problemType, message any){
+	// The format depend on the [Accept] header, let any caches know about it
+	if app.config.errorFormat != "problem" {
+		addVary(w.Header(), "Accept")
+	}
+
+	// The messages are translated to the language of the [Accept-Language] header
+	addVary(w.Header(), "Accept-Language")
+	w.Header().Set("Content-Language", app.language(r))
+
+	var (
+		env     envelope
+		headers http.Header
+	)
+
+	if app.wantsProblemJSON(r) {
+		env = app.problemEnvelope(r, status, problem, message)
+		headers = http.Header{"Content-Type": {"application/problem+json"}}
+	} else {
+		env = envelope{"error":message}
+
+		if v, ok := message.(*validator.Validator); ok {
+			env["error"] = v.Errors
+			env["field_errors"] = v.FieldErrors
+		}
+
+		// Include the request ID in the server error response, so the client
+		// can report it and we can find the matching log entries
+		if status >= http.StatusInternalServerError {
+			env["request_id"] = app.contextGetRequestID(r)
+		}
+	}
 
 	// Write response use [helper] package writeJSON() helper function
-	err:= app.writeJSON(w, status, env, nil)
+	err:= app.writeJSON(w, status, env, headers)
 	if err!=nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
 	}
 }
 
+// [language()] return the language of the error messages, chosen by the [Accept-Language] header
+func (app *application) language(r *http.Request) string {
+	return i18n.Negotiate(strings.Join(r.Header.Values("Accept-Language"), ","))
+}
+
+// [message()] translate the message by its code to the language of the request,
+// the English [message] is used for the default language and the unknown codes
+func (app *application) message(r *http.Request, code, message string, params map[string]any) string {
+	return i18n.Message(app.language(r), code, params, message)
+}
+
+// [localizeValidator()] return a copy of the validator with the messages
+// translated to the language of the request, by the error codes
+func (app *application) localizeValidator(r *http.Request, v *validator.Validator) *validator.Validator {
+	language := app.language(r)
+	if language == i18n.DefaultLanguage {
+		return v
+	}
+
+	localized := validator.New()
+
+	for _, e := range v.FieldErrors {
+		e.Message = i18n.Message(language, e.Code, e.Params, e.Message)
+		localized.Add(e)
+	}
+
+	return localized
+}
+
 // Response with server error
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error){
 	app.logError(r, err)
 
-	message:= "the server encountered a problem and could not proces your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	message:= app.message(r, "server_error", "the server encountered a problem and could not proces your request", nil)
+	app.errorResponse(w, r, http.StatusInternalServerError, problemServerError, message)
 }
 
 // Response 404 Not Found 
 func(app *application) notFoundResponse(w http.ResponseWriter, r *http.Request){
-	message:="the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	message:=app.message(r, "not_found", "the requested resource could not be found", nil)
+	app.errorResponse(w, r, http.StatusNotFound, problemNotFound, message)
 }
 
 // Response 405 methodNotAllowedResponse()
 func(app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request){
-	message:= fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	message:= app.message(r, "method_not_allowed", fmt.Sprintf("the %s method is not supported for this resource", r.Method), map[string]any{"method": r.Method})
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, problemMethodNotAllowed, message)
 }
 
 // Response 400 Bad Request 
+// the errors of a badly-formed request body are translated by their codes
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error){
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	message := err.Error()
+
+	var bodyErr *bodyError
+	if errors.As(err, &bodyErr) {
+		message = app.message(r, bodyErr.code, bodyErr.message, bodyErr.params)
+	}
+
+	app.errorResponse(w, r, http.StatusBadRequest, problemBadRequest, message)
+}
+
+// Response 422 Unprocessable Entity, the [error] member is the first message of each field
+// like before, the [field_errors] member list all of them with the codes and JSON pointer paths
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator){
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, problemFailedValidation, app.localizeValidator(r, v))
+}
+
+
+// Response 409 Conflict
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, "edit_conflict", "unable to update the record due to an edit conflict, please try again", nil)
+	app.errorResponse(w, r, http.StatusConflict, problemEditConflict, message)
+}
+
+// Response 412 Precondition Failed, the [If-Match] header doesn't match the current [ETag]
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, "precondition_failed", "the resource has been changed since it was last fetched, please fetch it again", nil)
+	app.errorResponse(w, r, http.StatusPreconditionFailed, problemPreconditionFailed, message)
 }
 
-// Response 422 Unprocessable Entity
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string){
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+// Response 403 Forbidden, the user doesn't have the required permission
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, "not_permitted", "your user account doesn't have the necessary permissions to access this resource", nil)
+	app.errorResponse(w, r, http.StatusForbidden, problemNotPermitted, message)
 }
 
+// Response 401 Unauthorized, the email or password is wrong
+func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, "invalid_credentials", "invalid authentication credentials", nil)
+	app.errorResponse(w, r, http.StatusUnauthorized, problemInvalidCredentials, message)
+}
+
+// Response 401 Unauthorized, the bearer token is missing, wrong or expired.
+// Include a [WWW-Authenticate: Bearer] header to tell the client how to authenticate
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := app.message(r, "invalid_authentication_token", "invalid or missing authentication token", nil)
+	app.errorResponse(w, r, http.StatusUnauthorized, problemInvalidAuthenticationToken, message)
+}
+
+// Response 401 Unauthorized, the anonymous user try to access a protected resource
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, "authentication_required", "you must be authenticated to access this resource", nil)
+	app.errorResponse(w, r, http.StatusUnauthorized, problemAuthenticationRequired, message)
+}
+
+// Response 403 Forbidden, the user account is not activated yet
+func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, "inactive_account", "your user account must be activated to access this resource", nil)
+	app.errorResponse(w, r, http.StatusForbidden, problemInactiveAccount, message)
+}
+
+// Response 429 Too Many Requests
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, "rate_limit_exceeded", "rate limit exceeded", nil)
+	app.errorResponse(w, r, http.StatusTooManyRequests, problemRateLimitExceeded, message)
+}
@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
+	"greenlight.wolfheros.com/internal/data"
+	"greenlight.wolfheros.com/internal/validator"
 )
 
 // Define an envelope type for enveloping the data result.
@@ -47,18 +51,33 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 	// append newline to json result
 	js = append(js, '\n')
 
+	// add the [Content-Type: application/json] first,
+	// so the headers can override it, such as [application/problem+json]
+	w.Header().Set("Content-Type", "application/json")
+
 	// add header in the response
 	for key, value := range headers {
 		w.Header()[key] = value
 	}
 
-	// add the [Content-Type: application/json], and [status code], [json]
-	w.Header().Set("Content-Type", "application/json")
+	// add [status code], [json]
 	w.WriteHeader(status)
 	w.Write(js)
 	return nil
 }
 
+// [bodyError] is returned by [readJSON()] when the request body can't be decoded,
+// the [code] and [params] are used to translate the message by [badRequestResponse()]
+type bodyError struct {
+	code    string
+	message string
+	params  map[string]any
+}
+
+func (e *bodyError) Error() string {
+	return e.message
+}
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error{
 
 	// Limits the max size of request body to 1MB by use [http.MaxBytesReader()]
@@ -89,43 +108,67 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 		// Use [errors.As()] function to check whether the error has the type *json.SyntaxError
 		// return error message and the location of problem
 		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contain badly-formed JSON (at charact %d)", syntaxError.Offset)
+			return &bodyError{
+				code:    "malformed_json",
+				message: fmt.Sprintf("body contain badly-formed JSON (at charact %d)", syntaxError.Offset),
+				params:  map[string]any{"offset": syntaxError.Offset},
+			}
 
 		// check is or not a [io.ErrUnexpectedEOF]
 		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contain badly-formed JSON")
+			return &bodyError{code: "incomplete_json", message: "body contain badly-formed JSON"}
 
 		// catch any *json.UnmarshalTypeError errors
 		case errors.As(err,&unmarshalTypeError):
 			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for filed %q", unmarshalTypeError.Field)
+				return &bodyError{
+					code:    "incorrect_json_type",
+					message: fmt.Sprintf("body contains incorrect JSON type for filed %q", unmarshalTypeError.Field),
+					params:  map[string]any{"field": unmarshalTypeError.Field},
+				}
+			}
+			return &bodyError{
+				code:    "incorrect_json_type_at",
+				message: fmt.Sprintf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset),
+				params:  map[string]any{"offset": unmarshalTypeError.Offset},
 			}
-			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
 		
 			// if the body is Empty
 		// it will return a EOF error
 		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+			return &bodyError{code: "empty_body", message: "body must not be empty"}
 		
 		// If the JSON contains a field which cnannot to be mapped to the target destination
 		// then [Decoder()] will now return an error message in the format ["json: unknown field"]
 		// there is a disccution about go try to take this error to a distinct error type in the future.
 		case strings.HasPrefix(err.Error(), "json: unknown field "):
 			fildName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			return fmt.Errorf("body contains unknown key %s", fildName)
+			return &bodyError{
+				code:    "unknown_field",
+				message: fmt.Sprintf("body contains unknown key %s", fildName),
+				params:  map[string]any{"field": strings.Trim(fildName, `"`)},
+			}
 
 		// Use the [errors.As()] check whether the error has the type [http.MaxBytesError]
 		case errors.As(err, &maxBytesError):
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+			return &bodyError{
+				code:    "body_too_large",
+				message: fmt.Sprintf("body must not be larger than %d bytes", maxBytesError.Limit),
+				params:  map[string]any{"limit": maxBytesError.Limit},
+			}
 		// [json.InvalidUnmarshalError] error will be returned
 		// when a invalid arguments pass to [Decode()]
 		// panic VS return
 		case errors.As(err, &invalidUnmarshalError):
 			panic(err)
 
-		// Default return any other error
+		// The runtime is decoded by [data.Runtime.UnmarshalJSON()]
+		case errors.Is(err, data.ErrInvalidRuntimeFormat):
+			return &bodyError{code: "invalid_runtime", message: err.Error()}
+
+		// Default return any other error, with a generic code so it is still translated
 		default:
-			return err
+			return &bodyError{code: "invalid_body", message: err.Error()}
 		}
 	}
 
@@ -138,7 +181,183 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 	
 	err = dec.Decode(&struct{}{})
 	if !errors.Is(err, io.EOF) {
-		return errors.New("body must only contain a single JSON value per request")
+		return &bodyError{code: "multiple_json_values", message: "body must only contain a single JSON value per request"}
 	}
 	return nil
 }
+
+// [readString()] return a string value from the query string,
+// or the provided default value if no matching key could be found
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// [readCSV()] read a string value from the query string and split it
+// into a slice on the comma character. Return the default value if no matching key exists.
+func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+	csv := qs.Get(key)
+
+	if csv == "" {
+		return defaultValue
+	}
+
+	return strings.Split(csv, ",")
+}
+
+// [readInt()] read a string value from the query string and convert it to an integer.
+// If the value couldn't be converted, record an error message in the [Validator] instance.
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddErrorCode(key, "integer", "must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
+
+// [movieResponse] is the JSON representation of a movie, with the runtime
+// rendered in the format requested by the client. The [Runtime] field hide
+// the one of the embedded [data.Movie], the other fields are kept as they are.
+type movieResponse struct {
+	*data.Movie
+	Runtime json.RawMessage `json:"runtime,omitempty"`
+}
+
+func newMovieResponse(movie *data.Movie, format data.RuntimeFormat) movieResponse {
+	response := movieResponse{Movie: movie}
+
+	// A zero runtime is left out, like the [omitempty] of [data.Movie]
+	if movie.Runtime != 0 {
+		// [MarshalJSONFormat()] never fail, it only format an integer
+		response.Runtime, _ = movie.Runtime.MarshalJSONFormat(format)
+	}
+
+	return response
+}
+
+// [versionETag()] return the strong [ETag] of a record, the version is
+// incremented on each change, so it identifies the representation. The runtime
+// format change the representation too, so it is added unless it is the default one.
+func versionETag(version int32, format data.RuntimeFormat) string {
+	if format == data.RuntimeFormatMins {
+		return strconv.Quote(strconv.FormatInt(int64(version), 10))
+	}
+
+	return strconv.Quote(fmt.Sprintf("%d-%s", version, format))
+}
+
+// [versionMatches()] report whether the [If-Match] header match the version of
+// the record, in any runtime format, all of them represent the same version
+func versionMatches(header string, version int32) bool {
+	for _, format := range data.RuntimeFormats {
+		if etagMatches(header, versionETag(version, format), false) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// [etagMatches()] report whether the [If-Match] or [If-None-Match] header value
+// match the [ETag]. The value is a comma-separated list of ETags, or "*" which
+// match any current representation. [If-Match] use the strong comparison,
+// so a weak ETag (W/"...") never match, [If-None-Match] use the weak comparison.
+func etagMatches(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// [readRuntimeFormat()] return the runtime format the client ask for, by the [runtime_format]
+// query string parameter, or the [runtime-format] parameter of the [Accept] header such as
+// [Accept: application/json; runtime-format=iso8601]. The query string take precedence.
+// An unknown format is recorded in the [Validator] instance.
+func (app *application) readRuntimeFormat(w http.ResponseWriter, r *http.Request, v *validator.Validator) data.RuntimeFormat {
+	// The response depend on the [Accept] header, let any caches know about it
+	addVary(w.Header(), "Accept")
+
+	name := r.URL.Query().Get("runtime_format")
+
+	if name == "" {
+	accept:
+		for _, accept := range r.Header.Values("Accept") {
+			for _, mediaRange := range strings.Split(accept, ",") {
+				_, params, err := mime.ParseMediaType(mediaRange)
+				if err == nil && params["runtime-format"] != "" {
+					name = params["runtime-format"]
+					break accept
+				}
+			}
+		}
+	}
+
+	if name == "" {
+		return data.RuntimeFormatMins
+	}
+
+	format, ok := data.ParseRuntimeFormat(name)
+	// The message is the same as the one of the [permitted_value] code in the catalogs
+	v.CheckCode(ok, "runtime_format", "permitted_value", "must be one of mins, iso8601, integer", "values", []string{"mins", "iso8601", "integer"})
+
+	return format
+}
+
+// [addVary()] add the header name to the [Vary] header, unless it is already there
+func addVary(headers http.Header, name string) {
+	for _, vary := range headers.Values("Vary") {
+		for _, existing := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), name) {
+				return
+			}
+		}
+	}
+
+	headers.Add("Vary", name)
+}
+
+// [background()] run the function in a new goroutine, any panic in the
+// goroutine will be recovered and logged, instead of terminating the application.
+// The goroutine is tracked by [app.wg], so the shutdown can wait for it to finish.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		// Decrement the WaitGroup counter when the goroutine finish
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%v", err))
+			}
+		}()
+
+		fn()
+	}()
+}
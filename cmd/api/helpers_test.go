@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"greenlight.wolfheros.com/internal/data"
+	"greenlight.wolfheros.com/internal/i18n"
+)
+
+func TestReadJSON(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantErr  string
+		wantCode string
+	}{
+		{
+			name: "Valid",
+			body: `{"title": "Moana"}`,
+		},
+		{
+			name:     "Syntax error",
+			body:     `<title>Moana</title>`,
+			wantErr:  "body contain badly-formed JSON (at charact 1)",
+			wantCode: "malformed_json",
+		},
+		{
+			name:     "Unexpected EOF",
+			body:     `{"title": "Moana"`,
+			wantErr:  "body contain badly-formed JSON",
+			wantCode: "incomplete_json",
+		},
+		{
+			name:     "Wrong type for field",
+			body:     `{"title": 123}`,
+			wantErr:  `body contains incorrect JSON type for filed "title"`,
+			wantCode: "incorrect_json_type",
+		},
+		{
+			name:     "Wrong type",
+			body:     `["Moana"]`,
+			wantErr:  "body contains incorrect JSON type (at character 1)",
+			wantCode: "incorrect_json_type_at",
+		},
+		{
+			name:     "Empty body",
+			body:     ``,
+			wantErr:  "body must not be empty",
+			wantCode: "empty_body",
+		},
+		{
+			name:     "Unknown field",
+			body:     `{"title": "Moana", "rating": "PG"}`,
+			wantErr:  `body contains unknown key "rating"`,
+			wantCode: "unknown_field",
+		},
+		{
+			name:     "Too large",
+			body:     `{"title": "` + strings.Repeat("a", 1_048_576) + `"}`,
+			wantErr:  "body must not be larger than 1048576 bytes",
+			wantCode: "body_too_large",
+		},
+		{
+			name:     "Multiple values",
+			body:     `{"title": "Moana"}{"title": "Frozen"}`,
+			wantErr:  "body must only contain a single JSON value per request",
+			wantCode: "multiple_json_values",
+		},
+		{
+			name:     "Invalid runtime",
+			body:     `{"title": "Moana", "runtime": "soon"}`,
+			wantErr:  "invalid runtime format",
+			wantCode: "invalid_runtime",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input struct {
+				Title   string       `json:"title"`
+				Runtime data.Runtime `json:"runtime"`
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+			err := app.readJSON(w, r, &input)
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("want error %q; got nil", tt.wantErr)
+			case tt.wantErr != "" && err.Error() != tt.wantErr:
+				t.Errorf("want error %q; got %q", tt.wantErr, err.Error())
+			case tt.wantErr == "" && input.Title != "Moana":
+				t.Errorf("want title %q; got %q", "Moana", input.Title)
+			}
+
+			if tt.wantCode == "" {
+				return
+			}
+
+			// The code is used to translate the message, the English
+			// catalog must give back the same message
+			var bodyErr *bodyError
+			if !errors.As(err, &bodyErr) {
+				t.Fatalf("want a *bodyError; got %T", err)
+			}
+			if bodyErr.code != tt.wantCode {
+				t.Errorf("want code %q; got %q", tt.wantCode, bodyErr.code)
+			}
+			if got := i18n.Message("en", bodyErr.code, bodyErr.params, ""); got != tt.wantErr {
+				t.Errorf("want English catalog message %q; got %q", tt.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestReadJSONInvalidDestination(t *testing.T) {
+	app := newTestApplication(t)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic for a non-pointer destination")
+		}
+	}()
+
+	var input struct{}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+
+	app.readJSON(w, r, input)
+}
@@ -2,17 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"expvar"
 	"flag"
 	"fmt"
 	"log/slog"
-	"net/http"
+	"net"
 	"os"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	// Import the pq driver so that it can register itself with the database/sql package
 	_ "github.com/lib/pq"
 	"greenlight.wolfheros.com/internal/data"
+	"greenlight.wolfheros.com/internal/mailer"
+	"greenlight.wolfheros.com/internal/metrics"
+	"greenlight.wolfheros.com/internal/validator"
 )
 
 const version = "1.0.0"
@@ -24,11 +32,46 @@ const version = "1.0.0"
 type config struct {
 	port int
 	env  string
+	adminToken string
+	shutdownTimeout time.Duration
+	errorFormat string
+	defaultPermissions []string
+	cursor struct{
+		secret string
+		maxAge time.Duration
+	}
+	limiter struct{
+		rps            float64
+		burst          int
+		enabled        bool
+		trustedProxies []*net.IPNet
+	}
+	metrics struct{
+		addr     string
+		username string
+		password string
+	}
+	cors struct{
+		trustedOrigins []string
+	}
+	smtp struct{
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
 	db struct{
+		driver string
 		dsn string
 		maxOpenConns int
 		maxIdleConns int
 		maxIdleTime	time.Duration	
+		migrateOnStart bool
+	}
+	demo struct{
+		email    string
+		password string
 	}
 }
 
@@ -38,6 +81,16 @@ type application struct {
 	config config
 	logger *slog.Logger
 	models data.Models
+	mailer mailer.Sender
+	wg     sync.WaitGroup
+
+	// The rate limiter of each client, used by the [rateLimit()] middleware
+	limiters *clientLimiters
+
+	// Prometheus metrics registry, and the request duration histogram
+	// observed by the [metrics()] middleware
+	registry        *metrics.Registry
+	requestDuration *metrics.HistogramVec
 }
 
 func main() {
@@ -46,6 +99,11 @@ func main() {
 	// Read value from command line while starting the application.
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
 	flag.StringVar(&cfg.env, "env", "development", "Enviroment(development|staging|production)")
+	flag.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 30 * time.Second, "Graceful shutdown deadline")
+	flag.StringVar(&cfg.errorFormat, "error-format", "json", "Error response format (json|problem), clients can ask for problem+json by the Accept header")
+
+	// Read the storage driver, [memory] keep all the data in the process and doesn't need a database
+	flag.StringVar(&cfg.db.driver, "db-driver", "postgres", "Storage driver (postgres|memory)")
 
 	// Read [DSN] value from the db-dsn command-line flag
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
@@ -54,12 +112,165 @@ func main() {
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", 15 * time.Minute, "PostgreSQL max connction idle time")
+
+	// Apply the pending migrations before starting the server, so a fresh environment come up ready
+	flag.BoolVar(&cfg.db.migrateOnStart, "db-migrate-on-start", false, "Apply pending database migrations on start")
+
+	// Read the admin token used by the purge endpoint, it is deprecated by the [movies:purge] permission
+	flag.StringVar(&cfg.adminToken, "admin-token", os.Getenv("GREENLIGHT_ADMIN_TOKEN"), "Admin token for admin-only endpoints (deprecated, use the movies:purge permission)")
+
+	// Read the demo user created with the [memory] driver, there is no database to activate
+	// the users or grant them the permissions, and maybe no SMTP server to get the token
+	flag.StringVar(&cfg.demo.email, "demo-email", "", "Email of an activated demo user with all the permissions (memory driver only)")
+	flag.StringVar(&cfg.demo.password, "demo-password", os.Getenv("GREENLIGHT_DEMO_PASSWORD"), "Password of the demo user (memory driver only)")
+
+	// Read the secret used to sign the pagination cursors, and how long a cursor stay valid
+	flag.StringVar(&cfg.cursor.secret, "cursor-secret", os.Getenv("GREENLIGHT_CURSOR_SECRET"), "Secret key for signing pagination cursors")
+	flag.DurationVar(&cfg.cursor.maxAge, "cursor-max-age", 24 * time.Hour, "Maximum age of a pagination cursor")
+
+	// Read the permission codes granted to every new user, as a space-separated list
+	cfg.defaultPermissions = []string{"movies:read"}
+	flag.Func("default-permissions", "Permissions granted to new users (space separated)", func(val string) error {
+		cfg.defaultPermissions = strings.Fields(val)
+		return nil
+	})
+
+	// Read the rate limiter settings, the requests per second and burst value
+	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
+	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
+	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+
+	// Read the trusted proxies as a space-separated list of IP addresses or CIDR networks,
+	// only these proxies can set the client IP by [X-Forwarded-For] and [X-Real-IP] headers
+	flag.Func("limiter-trusted-proxies", "Trusted proxy IPs or CIDRs (space separated)", func(val string) error {
+		for _, value := range strings.Fields(val) {
+			network, err := parseIPNet(value)
+			if err != nil {
+				return err
+			}
+			cfg.limiter.trustedProxies = append(cfg.limiter.trustedProxies, network)
+		}
+		return nil
+	})
+
+	// Read the trusted CORS origins as a space-separated list, such as
+	// "https://www.example.com https://staging.example.com"
+	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
+		cfg.cors.trustedOrigins = strings.Fields(val)
+		return nil
+	})
+
+	// Read the Prometheus metrics settings, the metrics are served on a separate
+	// address or protected by basic auth, otherwise they are not exposed at all
+	flag.StringVar(&cfg.metrics.addr, "metrics-addr", "", "Separate listen address for Prometheus metrics (e.g. 127.0.0.1:9090)")
+	flag.StringVar(&cfg.metrics.username, "metrics-username", "", "Basic auth username for Prometheus metrics")
+	flag.StringVar(&cfg.metrics.password, "metrics-password", os.Getenv("GREENLIGHT_METRICS_PASSWORD"), "Basic auth password for Prometheus metrics")
+
+	// Read the SMTP server settings, used for sending the activation emails
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "localhost", "SMTP host")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", os.Getenv("GREENLIGHT_SMTP_USERNAME"), "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("GREENLIGHT_SMTP_PASSWORD"), "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.wolfheros.com>", "SMTP sender")
 	// Reading all the input value frome commander line
 	flag.Parse()
 
+	if cfg.errorFormat != "json" && cfg.errorFormat != "problem" {
+		fmt.Fprintf(os.Stderr, "invalid -error-format %q, must be json or problem\n", cfg.errorFormat)
+		os.Exit(2)
+	}
+
+	if cfg.db.driver != "postgres" && cfg.db.driver != "memory" {
+		fmt.Fprintf(os.Stderr, "invalid -db-driver %q, must be postgres or memory\n", cfg.db.driver)
+		os.Exit(2)
+	}
+
+	// An empty password would let anyone who know the username read the metrics
+	if cfg.metrics.username != "" && cfg.metrics.password == "" {
+		fmt.Fprintln(os.Stderr, "-metrics-username requires -metrics-password (or GREENLIGHT_METRICS_PASSWORD)")
+		os.Exit(2)
+	}
+
+	if cfg.demo.email != "" && cfg.db.driver != "memory" {
+		fmt.Fprintln(os.Stderr, "-demo-email requires -db-driver=memory")
+		os.Exit(2)
+	}
+
+	// [migrate] is the only subcommand, and there is nothing to migrate without a database
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "migrate" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+			os.Exit(2)
+		}
+		if cfg.db.driver == "memory" {
+			fmt.Fprintln(os.Stderr, "the migrate command requires -db-driver=postgres")
+			os.Exit(2)
+		}
+	}
+
 	//Initial a structed logger which write log entries to the standard out steam.
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	// In production, write the log entries as JSON, so they can be parsed by the log collector
+	var logger *slog.Logger
+	if cfg.env == "production" {
+		logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	} else {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+
+	// If no cursor secret is provided, generate a random one,
+	// the cursors issued before a restart will no longer be accepted.
+	if cfg.cursor.secret == "" {
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		cfg.cursor.secret = string(secret)
+	}
+
+
+	// Publish the application version, the number of active goroutines and the
+	// current Unix timestamp in [expvar].
+	// The [expvar.Func] values are calculated each time the metrics are requested.
+	expvar.NewString("version").Set(version)
 
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+
+	expvar.Publish("timestamp", expvar.Func(func() any {
+		return time.Now().Unix()
+	}))
+
+	if cfg.adminToken != "" {
+		logger.Warn("the -admin-token flag is deprecated and will be removed in the next release, grant the movies:purge permission instead")
+	}
+
+	// With the [memory] driver, the API run without any database, the data
+	// is lost when the process exit. Used for demos and tests.
+	if cfg.db.driver == "memory" {
+		logger.Warn("using the in-memory storage, nothing will be persisted")
+		app := newApplication(cfg, logger, data.NewMemoryModels())
+
+		if cfg.demo.email != "" {
+			err := app.createDemoUser(cfg.demo.email, cfg.demo.password)
+			if err != nil {
+				logger.Error(err.Error())
+				os.Exit(1)
+			}
+			logger.Info("demo user created", "email", cfg.demo.email)
+		} else {
+			logger.Warn("no demo user, the movie routes need an activated user with the permissions, set -demo-email and -demo-password")
+		}
+
+		err := app.serve()
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 
 	// create db connection pool
 	db, err := openDB(cfg)
@@ -74,38 +285,113 @@ func main() {
 	// Logging a message to say the connection pool has been successfully establised
 	logger.Info("database connection pool established")
 
+	// Run the [migrate] subcommand instead of the server, such as: api migrate up
+	if args := flag.Args(); len(args) > 0 {
+		err = runMigrate(db, logger, args[1:])
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.db.migrateOnStart {
+		err = runMigrate(db, logger, []string{"up"})
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Publish the database connection pool statistics in [expvar]
+	expvar.Publish("database", expvar.Func(func() any {
+		return db.Stats()
+	}))
+
 	//Use [data.NewModels()] function to initialize a Models struct.
+	app := newApplication(cfg, logger, data.NewModels(db))
+
+	// Register the database pool gauges
+	app.registry.Register(dbMetrics(db)...)
+
+	// Call [app.serve()] to start the server, it only return once the
+	// server has been shut down gracefully (nil) or failed to start
+	err = app.serve()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// [newApplication()] create the application with the given models, and register
+// the request duration histogram in a new metrics registry
+func newApplication(cfg config, logger *slog.Logger, models data.Models) *application {
 	app := &application{
 		config: cfg,
 		logger: logger,
-		models: data.NewModels(db),
+		models: models,
+		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		registry: metrics.NewRegistry(),
+		limiters: newClientLimiters(),
 	}
 
-	//Declare a new servermux
-	// add a /vi/healthcheck route
+	app.requestDuration = metrics.NewHistogramVec(
+		"greenlight_http_request_duration_seconds",
+		"Duration of HTTP requests in seconds.",
+		[]string{"route", "method", "status"},
+		metrics.DefaultBuckets,
+	)
+	app.registry.Register(app.requestDuration)
 
-	// mux := http.NewServeMux()
-	// mux.HandleFunc("/v1/healthcheck", app.healthcheckHandler)
+	return app
+}
 
-	// Declare a Http server listen on the port provide in the config
-	// usse servermux as the handler
-	// contain, time out, and log message
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.port),
-		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+// [dbMetrics()] return the Prometheus gauges and counters of the [db.Stats()]
+func dbMetrics(db *sql.DB) []metrics.Collector {
+	return []metrics.Collector{
+		metrics.NewGaugeFunc("greenlight_db_max_open_connections", "Maximum number of open connections to the database.", func() float64 {
+			return float64(db.Stats().MaxOpenConnections)
+		}),
+		metrics.NewGaugeFunc("greenlight_db_open_connections", "Number of established connections, both in use and idle.", func() float64 {
+			return float64(db.Stats().OpenConnections)
+		}),
+		metrics.NewGaugeFunc("greenlight_db_in_use_connections", "Number of connections currently in use.", func() float64 {
+			return float64(db.Stats().InUse)
+		}),
+		metrics.NewGaugeFunc("greenlight_db_idle_connections", "Number of idle connections.", func() float64 {
+			return float64(db.Stats().Idle)
+		}),
+		metrics.NewCounterFunc("greenlight_db_wait_count_total", "Total number of connections waited for.", func() float64 {
+			return float64(db.Stats().WaitCount)
+		}),
+		metrics.NewCounterFunc("greenlight_db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", func() float64 {
+			return db.Stats().WaitDuration.Seconds()
+		}),
+		metrics.NewCounterFunc("greenlight_db_max_idle_time_closed_total", "Total number of connections closed due to max idle time.", func() float64 {
+			return float64(db.Stats().MaxIdleTimeClosed)
+		}),
 	}
+}
 
-	//start the HTTP server
-	logger.Info("starting server", "addr", srv.Addr, "env", cfg.env)
+// [parseIPNet()] parse a CIDR network, a single IP address is
+// converted to a network which only contain the address itself
+func parseIPNet(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		return network, err
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", value)
+	}
+
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
 
-	// because the err already declared, so here change from := to =
-	err = srv.ListenAndServe()
-	logger.Error(err.Error())
-	os.Exit(1)
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
 }
 
 // [openDB()] funtion return a [sql.DB] connection pool
@@ -142,3 +428,24 @@ func openDB(cfg config)(*sql.DB, error){
 	// return the db connection pool
 	return db, nil
 }
+
+// [demoPermissions] are granted to the demo user, all the permissions of the movie routes
+var demoPermissions = []string{"movies:read", "movies:write", "movies:purge"}
+
+// [createDemoUser()] create an activated user with all the permissions, so the API
+// can be tried with the [memory] driver without activating a user by email
+func (app *application) createDemoUser(email, plaintextPassword string) error {
+	user := &data.User{Name: "Demo", Email: email, Activated: true}
+
+	v := validator.New()
+	if data.ValidateNewUser(v, user, plaintextPassword); !v.Valid() {
+		return fmt.Errorf("invalid demo user: %v", v.Errors)
+	}
+
+	err := user.Password.Set(plaintextPassword)
+	if err != nil {
+		return err
+	}
+
+	return app.models.Users.Insert(user, demoPermissions...)
+}
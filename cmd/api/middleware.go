@@ -1,8 +1,25 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"expvar"
 	"fmt"
+	"net"
 	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"greenlight.wolfheros.com/internal/data"
+	"greenlight.wolfheros.com/internal/validator"
 )
 
 func (app *application) recoverPanic(next http.Handler) http.Handler{
@@ -34,4 +51,501 @@ func (app *application) recoverPanic(next http.Handler) http.Handler{
 		// 3-> This line won't be called until the request be servered in the [Server.ListenAndServe()] method
 		next.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
+
+// Declare the expvar variables of the [metrics()] middleware at package level,
+// [expvar] panic if the same name is published twice
+var (
+	totalRequestsReceived           = expvar.NewInt("total_requests_received")
+	totalResponsesSent              = expvar.NewInt("total_responses_sent")
+	totalProcessingTimeMicroseconds = expvar.NewInt("total_processing_time_μs")
+	totalResponsesSentByStatus      = expvar.NewMap("total_responses_sent_by_status")
+)
+
+// [standardMethods] are the method label values of the request duration histogram
+var standardMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// [metricsResponseWriter] wrap a [http.ResponseWriter] to record the
+// status code and the number of body bytes of the response
+type metricsResponseWriter struct {
+	wrapped       http.ResponseWriter
+	statusCode    int
+	headerWritten bool
+	bytesWritten  int
+}
+
+// [newMetricsResponseWriter()] return a new [metricsResponseWriter], the default
+// status code is 200 because it is what Go send if [WriteHeader()] is never called
+func newMetricsResponseWriter(w http.ResponseWriter) *metricsResponseWriter {
+	return &metricsResponseWriter{
+		wrapped:    w,
+		statusCode: http.StatusOK,
+	}
+}
+
+func (mw *metricsResponseWriter) Header() http.Header {
+	return mw.wrapped.Header()
+}
+
+// [WriteHeader()] record the status code of the first call, then pass it through
+func (mw *metricsResponseWriter) WriteHeader(statusCode int) {
+	mw.wrapped.WriteHeader(statusCode)
+
+	if !mw.headerWritten {
+		mw.statusCode = statusCode
+		mw.headerWritten = true
+	}
+}
+
+func (mw *metricsResponseWriter) Write(b []byte) (int, error) {
+	mw.headerWritten = true
+
+	n, err := mw.wrapped.Write(b)
+	mw.bytesWritten += n
+
+	return n, err
+}
+
+// [Unwrap()] return the wrapped [http.ResponseWriter], so that
+// [http.ResponseController] can still reach the underlying writer
+func (mw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return mw.wrapped
+}
+
+// [metrics()] middleware count the requests received and the responses sent,
+// the total processing time and the responses by status code.
+// The request duration is also observed by the Prometheus histogram, labelled
+// by the route pattern, method and status class (2xx, 4xx...)
+func (app *application) metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		totalRequestsReceived.Add(1)
+
+		mw := newMetricsResponseWriter(w)
+
+		r, info := app.contextRequestInfo(r)
+
+		next.ServeHTTP(mw, r)
+
+		// On the way back up the middleware chain
+		totalResponsesSent.Add(1)
+
+		totalResponsesSentByStatus.Add(strconv.Itoa(mw.statusCode), 1)
+
+		duration := time.Since(start)
+		totalProcessingTimeMicroseconds.Add(duration.Microseconds())
+
+		if app.requestDuration != nil {
+			// The requests which didn't match any route (404, 405 and automatic OPTIONS)
+			// share a single label value, so the raw paths can't explode the cardinality
+			pattern := info.route
+			if pattern == "" {
+				pattern = "unmatched"
+			}
+
+			// Any client can send an arbitrary method, only keep the standard ones
+			method := r.Method
+			if !slices.Contains(standardMethods, method) {
+				method = "other"
+			}
+
+			statusClass := strconv.Itoa(mw.statusCode/100) + "xx"
+
+			app.requestDuration.Observe(duration.Seconds(), pattern, method, statusClass)
+		}
+	})
+}
+
+// [requestIDRX] is the format of an accepted [X-Request-ID] header, the
+// value end up in the logs and the response, so only the safe characters are allowed
+var requestIDRX = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+// [requestID()] middleware accept the [X-Request-ID] header of the request (such as
+// the one set by a load balancer), or generate a new random ID. The ID is stored in the
+// request context and echoed in the response header.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+
+		if !requestIDRX.MatchString(requestID) {
+			b := make([]byte, 16)
+
+			_, err := rand.Read(b)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			requestID = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+
+		r = app.contextSetRequestID(r, requestID)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// [logRequest()] middleware write one structured access log line for each request,
+// once the response has been sent
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		mw := newMetricsResponseWriter(w)
+
+		r, info := app.contextRequestInfo(r)
+
+		next.ServeHTTP(mw, r)
+
+		app.logger.Info("request",
+			"request_id", app.contextGetRequestID(r),
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"route", info.route,
+			"status", mw.statusCode,
+			"bytes", mw.bytesWritten,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote_ip", app.clientIP(r),
+			"user_id", info.userID,
+		)
+	})
+}
+
+// [requireMetricsAuth()] middleware protect the Prometheus metrics with HTTP basic auth,
+// using the credentials from [-metrics-username] and [-metrics-password] flags
+func (app *application) requireMetricsAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+
+		// Use [subtle.ConstantTimeCompare()] to avoid timing attack,
+		// evaluate both comparisons so the time doesn't leak which one failed
+		usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(app.config.metrics.username)) == 1
+		passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(app.config.metrics.password)) == 1
+
+		if !ok || !usernameMatch || !passwordMatch {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics", charset="UTF-8"`)
+			app.invalidCredentialsResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// [enableCORS()] middleware allow the cross-origin requests from the trusted origins.
+// The [Access-Control-Allow-Origin] header is only set when the [Origin] of the request
+// is in the trusted list. The preflight requests are answered by [preflightCORS()].
+func (app *application) enableCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response will vary depending on the [Origin] header (and the
+		// [Access-Control-Request-Method] header for the preflight requests),
+		// always let any caches know about it
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+
+		origin := r.Header.Get("Origin")
+
+		if origin != "" && slices.Contains(app.config.cors.trustedOrigins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+
+			// Let the browser scripts read the [ETag] for the conditional requests
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// [preflightCORS()] is the [GlobalOPTIONS] handler of the router, it is called by
+// the automatic OPTIONS handling of [httprouter], after the [Allow] header has been set
+// to the methods supported by the requested path.
+func (app *application) preflightCORS(w http.ResponseWriter, r *http.Request) {
+	// A preflight request always contain the [Access-Control-Request-Method] header,
+	// and [enableCORS()] only set [Access-Control-Allow-Origin] for the trusted origins
+	if r.Header.Get("Access-Control-Request-Method") != "" && w.Header().Get("Access-Control-Allow-Origin") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", w.Header().Get("Allow"))
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match, X-Admin-Token")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// [rateLimit()] middleware keep a token bucket rate limiter for each client IP
+// address, the request will be rejected with [429 Too Many Requests] response once
+// the client used up its bucket.
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	mu, clients := &app.limiters.mu, app.limiters.clients
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.limiter.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip := app.clientIP(r)
+
+		mu.Lock()
+
+		// Initialize a new rate limiter for the new client
+		if _, found := clients[ip]; !found {
+			clients[ip] = &client{
+				limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
+			}
+		}
+
+		clients[ip].lastSeen = time.Now()
+
+		// [Allow()] consume one token from the bucket, return false if the bucket is empty
+		if !clients[ip].limiter.Allow() {
+			mu.Unlock()
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
+
+		// Don't use defer to unlock the mutex, that would wait until all
+		// the handlers downstream of this middleware have returned.
+		mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// [clientLimiters] hold the rate limiter and the last seen time of each client,
+// it is shared by all the handlers returned by [routes()]
+type clientLimiters struct {
+	mu      sync.Mutex
+	clients map[string]*client
+}
+
+// [client] struct hold the rate limiter and the last seen time of a client
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newClientLimiters() *clientLimiters {
+	return &clientLimiters{clients: make(map[string]*client)}
+}
+
+// [evict()] remove the clients that haven't been seen within the last three minutes,
+// once every minute, until the [done] channel is closed. It is started by [serve()].
+func (l *clientLimiters) evict(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			l.evictBefore(time.Now().Add(-3 * time.Minute))
+		}
+	}
+}
+
+// [evictBefore()] remove the clients last seen before the [cutoff]
+func (l *clientLimiters) evictBefore(cutoff time.Time) {
+	// Lock the mutex while the cleanup is taking place
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ip, c := range l.clients {
+		if c.lastSeen.Before(cutoff) {
+			delete(l.clients, ip)
+		}
+	}
+}
+
+// [clientIP()] return the IP address of the client. The [X-Forwarded-For] and
+// [X-Real-IP] headers can be forged by anyone, so they are only used when the
+// request come from one of the trusted proxies.
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !app.isTrustedProxy(ip) {
+		return ip
+	}
+
+	// Walk the [X-Forwarded-For] list from the right, each proxy append the address
+	// it received the request from, so the first untrusted address is the client.
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		addrs := strings.Split(strings.Join(xff, ","), ",")
+
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(addrs[i])
+			if net.ParseIP(addr) == nil {
+				break
+			}
+
+			ip = addr
+			if !app.isTrustedProxy(addr) {
+				return ip
+			}
+		}
+
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return ip
+}
+
+// [isTrustedProxy()] check whether the IP address is in one of the trusted proxy networks
+func (app *application) isTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, network := range app.config.limiter.trustedProxies {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// [authenticate()] middleware read the [Authorization: Bearer <token>] header,
+// and add the matching user to the request context. If there is no
+// [Authorization] header, the [data.AnonymousUser] is added instead.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response will vary depending on the [Authorization] header,
+		// let any caches know about it
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+
+		if authorizationHeader == "" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The header value should be in the format "Bearer <token>"
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		token := headerParts[1]
+
+		v := validator.New()
+
+		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		// Only the tokens which are not expired will be found
+		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		r = app.contextSetUser(r, user)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// [requireAuthenticatedUser()] middleware check that the user is not anonymous
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// [requireActivatedUser()] middleware check that the user is both authenticated and activated
+func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	// Wrap [fn] with [requireAuthenticatedUser()] middleware before returning it,
+	// so the anonymous user get a 401 response instead of 403
+	return app.requireAuthenticatedUser(fn)
+}
+
+// [requirePermission()] middleware check that the activated user has the permission code
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireActivatedUser(fn)
+}
+
+// [requireAdmin()] middleware only allow the request which carry the configured
+// admin token in the [X-Admin-Token] header, the request without the header
+// need the permission code instead.
+// Deprecated: the admin token is kept for one release, so the deployments can
+// grant the permission before it is removed, use [requirePermission()] instead.
+func (app *application) requireAdmin(code string, next http.HandlerFunc) http.HandlerFunc {
+	withPermission := app.requirePermission(code, next)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Admin-Token")
+		if token == "" {
+			withPermission(w, r)
+			return
+		}
+
+		// Use [subtle.ConstantTimeCompare()] to avoid timing attack
+		if app.config.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(app.config.adminToken)) != 1 {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		app.logger.Warn("the X-Admin-Token header is deprecated, grant the permission to the user instead", "permission", code, "request_id", app.contextGetRequestID(r))
+
+		next.ServeHTTP(w, r)
+	}
+}
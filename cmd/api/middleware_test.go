@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	app.recoverPanic(next).ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("want status %d; got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if got := w.Header().Get("Connection"); got != "close" {
+		t.Errorf("want Connection header %q; got %q", "close", got)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, "the server encountered a problem") {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestClientLimitersEvict(t *testing.T) {
+	l := newClientLimiters()
+
+	now := time.Now()
+	l.clients["192.0.2.1"] = &client{lastSeen: now.Add(-5 * time.Minute)}
+	l.clients["192.0.2.2"] = &client{lastSeen: now}
+
+	l.evictBefore(now.Add(-3 * time.Minute))
+
+	if _, found := l.clients["192.0.2.1"]; found {
+		t.Error("want the stale client to be evicted")
+	}
+	if _, found := l.clients["192.0.2.2"]; !found {
+		t.Error("want the recent client to be kept")
+	}
+
+	// The eviction goroutine return once the done channel is closed
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		l.evict(done)
+		close(stopped)
+	}()
+
+	close(done)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("want evict() to return after done is closed")
+	}
+}
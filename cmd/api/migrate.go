@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"greenlight.wolfheros.com/internal/migrate"
+	"greenlight.wolfheros.com/migrations"
+)
+
+// [migrateUsage] is printed when the [migrate] subcommand is used wrongly
+const migrateUsage = `usage: api [flags] migrate <command>
+
+commands:
+  up [N]      apply all (or the next N) pending migrations
+  down [N]    roll back the last migration (or the last N migrations, N >= 1)
+  down -all   roll back every migration, dropping all the tables
+  goto V      migrate up or down to version V
+  version     print the current version
+  force V     set the version without running any migration (-1 for none)`
+
+// [runMigrate()] run the [migrate] subcommand with the embedded migration files,
+// such as: api -db-dsn=postgres://... migrate up
+func runMigrate(db *sql.DB, logger *slog.Logger, args []string) error {
+	migrator, err := migrate.New(db, migrations.Files, logger)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	ctx := context.Background()
+
+	// [argument()] parse the optional (or required) numeric argument of the command
+	argument := func(required bool, defaultValue int64) (int64, error) {
+		switch {
+		case len(args) == 1 && !required:
+			return defaultValue, nil
+		case len(args) != 2:
+			return 0, errors.New(migrateUsage)
+		}
+
+		return strconv.ParseInt(args[1], 10, 64)
+	}
+
+	switch args[0] {
+	case "up":
+		n, argErr := argument(false, 0)
+		if argErr != nil {
+			return argErr
+		}
+		err = migrator.Up(ctx, int(n))
+
+	case "down":
+		// Rolling back everything drop all the tables, so it must be asked explicitly
+		// with [-all], a [0] or negative N is an error rather than "all of them"
+		if len(args) == 2 && args[1] == "-all" {
+			err = migrator.Down(ctx, 0)
+			break
+		}
+
+		n, argErr := argument(false, 1)
+		if argErr != nil {
+			return argErr
+		}
+		if n < 1 {
+			return fmt.Errorf("down: N must be at least 1, use \"down -all\" to roll back every migration\n\n%s", migrateUsage)
+		}
+		err = migrator.Down(ctx, int(n))
+
+	case "goto":
+		version, argErr := argument(true, 0)
+		if argErr != nil {
+			return argErr
+		}
+		err = migrator.Goto(ctx, version)
+
+	case "version":
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+
+		if version == -1 {
+			fmt.Println("no migration has been applied")
+			return nil
+		}
+
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+
+	case "force":
+		version, argErr := argument(true, 0)
+		if argErr != nil {
+			return argErr
+		}
+		err = migrator.Force(ctx, version)
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	// There is nothing to do is not a failure
+	if errors.Is(err, migrate.ErrNoChange) {
+		logger.Info("no change")
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMigrateDownArgument(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"Zero", []string{"down", "0"}, "N must be at least 1"},
+		{"Negative", []string{"down", "-1"}, "N must be at least 1"},
+		{"Not a number", []string{"down", "all"}, "invalid syntax"},
+		{"Too many arguments", []string{"down", "1", "2"}, "usage:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The arguments are checked before the database is used, so it can be nil
+			err := runMigrate(nil, logger, tt.args)
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("want error containing %q; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
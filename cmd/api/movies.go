@@ -1,9 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
 
 	"greenlight.wolfheros.com/internal/data"
 	"greenlight.wolfheros.com/internal/validator"
@@ -49,15 +49,34 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	// Initialize a new Validator instance to verify the client import
 	v:=validator.New()
 
+	// The runtime is rendered in the format requested by the client
+	format := app.readRuntimeFormat(w, r, v)
+
 	// At the end check is there any failed validation by checking validator instance
 	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w,r,v.Errors)
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Pass the validated movie struct to [Insert()] method, which will create
+	// the record in the database and update the struct with system-generated information
+	err = app.models.Movies.Insert(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Dumping the contents of the input struct in a http response
-	// fmt.Fprintln(w, "create a new movie")
-	fmt.Fprintf(w, "%+v\n", input)
+	// Include a [Location] header to let the client know which URL they can find
+	// the newly-created resource at.
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("ETag", versionETag(movie.Version, format))
+
+	// Response [201 Created] status code with the movie data
+	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": newMovieResponse(movie, format)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
 
 // show movie handler
@@ -71,17 +90,38 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// create a [Movies] struct instance, contain some dummy data
-	movie := data.Movie{
-		ID:        id,
-		CreatedAt: time.Now(),
-		Title:     "Casablance",
-		Runtime:   102,
-		Genres:    []string{"drama", "romance", "war"},
-		Version:   1,
+	// Fetch the data for a specific movie, use [errors.Is()] function
+	// to check whether it is a [data.ErrRecordNotFound] error
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+
+	format := app.readRuntimeFormat(w, r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Send the version as [ETag], if the client already has this version
+	// (If-None-Match), reply 304 Not Modified without the body
+	etag := versionETag(movie.Version, format)
+	w.Header().Set("ETag", etag)
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": newMovieResponse(movie, format)}, nil)
 	if err != nil {
 		// app.logger.Error(err.Error())
 		// http.Error(w, "The server encouted a problem and could not process your request", http.StatusInternalServerError)
@@ -90,3 +130,302 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 
 	// fmt.Fprintf(w, "show the details of movie %d\n", id)
 }
+
+// update movie handler
+// response to [PATCH /v1/movies/:id] endpoint
+func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Fetch the existing movie record from the database
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The [If-Match] header is an alternative to the [version] in the body,
+	// the update only go ahead if the client has the current version
+	match := r.Header.Get("If-Match")
+	if match != "" && !versionMatches(match, movie.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// Use pointers for the fields, so that we can tell the difference between
+	// a field which is not provided in the request body (nil) and a zero value.
+	// The optional [version] let client make sure it is updating the version it has seen.
+	var input struct {
+		Title   *string       `json:"title"`
+		Year    *int32        `json:"year"`
+		Runtime *data.Runtime `json:"runtime"`
+		Genres  []string      `json:"genres"`
+		Version *int32        `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// If client provide a version which doesn't match the stored one,
+	// the record has been changed since client read it
+	if input.Version != nil && *input.Version != movie.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	// Only copy the values which are provided in the request body
+	if input.Title != nil {
+		movie.Title = *input.Title
+	}
+	if input.Year != nil {
+		movie.Year = *input.Year
+	}
+	if input.Runtime != nil {
+		movie.Runtime = *input.Runtime
+	}
+	if input.Genres != nil {
+		movie.Genres = input.Genres
+	}
+
+	v := validator.New()
+
+	format := app.readRuntimeFormat(w, r, v)
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// [Update()] return [data.ErrEditConflict] if the version has been changed
+	// between our [Get()] and [Update()] call
+	err = app.models.Movies.Update(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && match != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(movie.Version, format))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": newMovieResponse(movie, format)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// delete movie handler
+// response to [DELETE /v1/movies/:id] endpoint, the movie is soft-deleted
+func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// With an [If-Match] header, only delete the movie if the client has the current version
+	match := r.Header.Get("If-Match")
+	if match != "" && !versionMatches(match, movie.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// [Delete()] return [data.ErrEditConflict] if the movie has been changed
+	// (or deleted) between our [Get()] and [Delete()] call
+	err = app.models.Movies.Delete(id, movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && match != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// restore movie handler
+// response to [POST /v1/movies/:id/restore] endpoint, undo a soft-delete
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	format := app.readRuntimeFormat(w, r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// [data.ErrRecordNotFound] mean the movie doesn't exist or it is not deleted
+	movie, err := app.models.Movies.Restore(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The restore bump the version, send the new one so the client can use it in [If-Match]
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(movie.Version, format))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": newMovieResponse(movie, format)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purge movie handler
+// response to [DELETE /v1/movies/:id/purge] endpoint, permanently remove the movie
+func (app *application) purgeMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Movies.Purge(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully purged"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// list movies handler
+// response to [GET /v1/movies] endpoint, support filtering, sorting and pagination
+// by the query string, such as:
+// /v1/movies?title=godfather&genres=crime,drama&page=1&page_size=5&sort=-year
+// Use the [cursor] parameter instead of [page] for keyset pagination, the cursor
+// come from the [next_cursor] or [prev_cursor] of the previous response.
+// The [q] parameter run a full-text search on the title, the results are sorted
+// by relevance (best matches first) unless another sort is requested.
+func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title  string
+		Genres []string
+		Search string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	// Get the [url.Values] map which contain the query string data
+	qs := r.URL.Query()
+
+	input.Title = app.readString(qs, "title", "")
+	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.Search = app.readString(qs, "q", "")
+
+	format := app.readRuntimeFormat(w, r, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	// Search results are sorted by relevance by default
+	defaultSort := "id"
+	if input.Search != "" {
+		defaultSort = "-relevance"
+	}
+
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
+	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+
+	// Sorting by relevance only make sense with a search query
+	if input.Search != "" {
+		input.Filters.SortSafelist = append(input.Filters.SortSafelist, "relevance", "-relevance")
+	}
+
+	// The cursor must be signed by us, not stale, and issued for the same listing parameters
+	if cursor := app.readString(qs, "cursor", ""); cursor != "" {
+		c, err := data.DecodeCursor(cursor, []byte(app.config.cursor.secret), app.config.cursor.maxAge)
+		if err != nil || !c.Matches(input.Title, input.Genres, input.Search, input.Filters.Sort) {
+			v.AddErrorCode("cursor", "invalid_cursor", "invalid or expired cursor")
+		} else {
+			input.Filters.Cursor = &c
+		}
+
+		v.CheckCode(!qs.Has("page"), "page", "exclusive", "must not be used together with cursor", "with", "cursor")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Search, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := make([]movieResponse, len(movies))
+	for i, movie := range movies {
+		response[i] = newMovieResponse(movie, format)
+	}
+
+	// Sign the cursors before sending them to the client
+	if metadata.Next != nil {
+		metadata.NextCursor = metadata.Next.Encode([]byte(app.config.cursor.secret))
+	}
+	if metadata.Prev != nil {
+		metadata.PrevCursor = metadata.Prev.Encode([]byte(app.config.cursor.secret))
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": response, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"greenlight.wolfheros.com/internal/data"
+)
+
+func TestMovieConditionalRequests(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	auth := newTestUser(t, app, "movies:read", "movies:write")
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}}
+
+	err := app.models.Movies.Insert(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// [withHeader()] return a copy of the [Authorization] header plus another one
+	withHeader := func(key, value string) http.Header {
+		headers := auth.Clone()
+		headers.Set(key, value)
+		return headers
+	}
+
+	status, headers, _ := ts.do(t, http.MethodGet, "/v1/movies/1", "", auth)
+	if status != http.StatusOK || headers.Get("ETag") != `"1"` {
+		t.Fatalf("want 200 with ETag %q; got %d with %q", `"1"`, status, headers.Get("ETag"))
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		headers    http.Header
+		wantStatus int
+		wantETag   string
+	}{
+		{
+			name:       "If-None-Match matches",
+			method:     http.MethodGet,
+			headers:    withHeader("If-None-Match", `"1"`),
+			wantStatus: http.StatusNotModified,
+			wantETag:   `"1"`,
+		},
+		{
+			name:       "If-None-Match weak and list",
+			method:     http.MethodGet,
+			headers:    withHeader("If-None-Match", `"7", W/"1"`),
+			wantStatus: http.StatusNotModified,
+			wantETag:   `"1"`,
+		},
+		{
+			name:       "If-None-Match doesn't match",
+			method:     http.MethodGet,
+			headers:    withHeader("If-None-Match", `"2"`),
+			wantStatus: http.StatusOK,
+			wantETag:   `"1"`,
+		},
+		{
+			name:       "If-Match doesn't match",
+			method:     http.MethodPatch,
+			body:       `{"title": "Moana 2"}`,
+			headers:    withHeader("If-Match", `"2"`),
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "If-Match weak",
+			method:     http.MethodPatch,
+			body:       `{"title": "Moana 2"}`,
+			headers:    withHeader("If-Match", `W/"1"`),
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "If-Match matches",
+			method:     http.MethodPatch,
+			body:       `{"title": "Moana 2"}`,
+			headers:    withHeader("If-Match", `"1"`),
+			wantStatus: http.StatusOK,
+			wantETag:   `"2"`,
+		},
+		{
+			name:       "Delete with stale If-Match",
+			method:     http.MethodDelete,
+			headers:    withHeader("If-Match", `"1"`),
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "Delete with current If-Match",
+			method:     http.MethodDelete,
+			headers:    withHeader("If-Match", `"2"`),
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, headers, body := ts.do(t, tt.method, "/v1/movies/1", tt.body, tt.headers)
+
+			if status != tt.wantStatus {
+				t.Fatalf("want status %d; got %d: %s", tt.wantStatus, status, body)
+			}
+
+			if tt.wantETag != "" && headers.Get("ETag") != tt.wantETag {
+				t.Errorf("want ETag %q; got %q", tt.wantETag, headers.Get("ETag"))
+			}
+
+			if status == http.StatusNotModified && body != "" {
+				t.Errorf("want empty body; got %s", body)
+			}
+		})
+	}
+
+	// The restore send the ETag of the new version, which can be used right away in [If-Match]
+	status, headers, body := ts.do(t, http.MethodPost, "/v1/movies/1/restore", "", auth)
+	if status != http.StatusOK {
+		t.Fatalf("want status %d; got %d: %s", http.StatusOK, status, body)
+	}
+
+	etag := headers.Get("ETag")
+	if etag == "" {
+		t.Fatal("want an ETag for the restored movie")
+	}
+
+	status, _, body = ts.do(t, http.MethodPatch, "/v1/movies/1", `{"title": "Moana"}`, withHeader("If-Match", etag))
+	if status != http.StatusOK {
+		t.Errorf("want status %d with If-Match %s; got %d: %s", http.StatusOK, etag, status, body)
+	}
+}
+
+func TestMovieRuntimeFormat(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	auth := newTestUser(t, app, "movies:read")
+
+	err := app.models.Movies.Insert(&data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		accept      string
+		wantStatus  int
+		wantRuntime string
+		wantETag    string
+	}{
+		{
+			name:        "Default",
+			urlPath:     "/v1/movies/1",
+			wantStatus:  http.StatusOK,
+			wantRuntime: `"runtime": "107 mins"`,
+			wantETag:    `"1"`,
+		},
+		{
+			name:        "Query string",
+			urlPath:     "/v1/movies/1?runtime_format=iso8601",
+			wantStatus:  http.StatusOK,
+			wantRuntime: `"runtime": "PT1H47M"`,
+			wantETag:    `"1-iso8601"`,
+		},
+		{
+			name:        "Accept parameter",
+			urlPath:     "/v1/movies/1",
+			accept:      "application/json; runtime-format=integer",
+			wantStatus:  http.StatusOK,
+			wantRuntime: `"runtime": 107`,
+			wantETag:    `"1-integer"`,
+		},
+		{
+			name:        "Query string take precedence",
+			urlPath:     "/v1/movies/1?runtime_format=mins",
+			accept:      "application/json; runtime-format=integer",
+			wantStatus:  http.StatusOK,
+			wantRuntime: `"runtime": "107 mins"`,
+			wantETag:    `"1"`,
+		},
+		{
+			name:        "List",
+			urlPath:     "/v1/movies?runtime_format=integer",
+			wantStatus:  http.StatusOK,
+			wantRuntime: `"runtime": 107`,
+		},
+		{
+			name:        "Unknown format",
+			urlPath:     "/v1/movies/1?runtime_format=hours",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantRuntime: `"runtime_format": "must be one of mins, iso8601, integer"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := auth.Clone()
+			if tt.accept != "" {
+				headers.Set("Accept", tt.accept)
+			}
+
+			status, rsHeaders, body := ts.do(t, http.MethodGet, tt.urlPath, "", headers)
+
+			if status != tt.wantStatus {
+				t.Fatalf("want status %d; got %d: %s", tt.wantStatus, status, body)
+			}
+
+			if !strings.Contains(body, tt.wantRuntime) {
+				t.Errorf("want body to contain %s; got %s", tt.wantRuntime, body)
+			}
+
+			if tt.wantETag != "" && rsHeaders.Get("ETag") != tt.wantETag {
+				t.Errorf("want ETag %q; got %q", tt.wantETag, rsHeaders.Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestCreateMovieValidation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	auth := newTestUser(t, app, "movies:write")
+
+	body := `{"title": "", "year": 2016, "runtime": "107 mins", "genres": ["animation", ""]}`
+
+	tests := []struct {
+		name   string
+		accept string
+		want   []string
+	}{
+		{
+			name: "JSON",
+			want: []string{
+				`"title": "must be provided"`,
+				`"genres[1]": "must be provided"`,
+				`"path": "/genres/1"`,
+				`"code": "required"`,
+			},
+		},
+		{
+			name:   "Problem JSON",
+			accept: "application/problem+json",
+			want: []string{
+				`"errors": {`,
+				`"genres[1]": "must be provided"`,
+				`"field_errors": [`,
+				`"path": "/title"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := auth.Clone()
+			if tt.accept != "" {
+				headers.Set("Accept", tt.accept)
+			}
+
+			status, _, rsBody := ts.do(t, http.MethodPost, "/v1/movies", body, headers)
+
+			if status != http.StatusUnprocessableEntity {
+				t.Fatalf("want status %d; got %d: %s", http.StatusUnprocessableEntity, status, rsBody)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(rsBody, want) {
+					t.Errorf("want body to contain %s; got %s", want, rsBody)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	auth := newTestUser(t, app, "movies:read", "movies:write")
+	auth.Set("Accept-Language", "es-ES, en;q=0.8")
+
+	status, headers, body := ts.do(t, http.MethodPost, "/v1/movies", `{"title": "", "year": 1700, "runtime": "107 mins", "genres": ["animation"]}`, auth)
+
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("want status %d; got %d: %s", http.StatusUnprocessableEntity, status, body)
+	}
+
+	for _, want := range []string{
+		`"title": "es obligatorio"`,
+		`"year": "debe estar entre 1888 y `,
+		`"code": "between"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want body to contain %s; got %s", want, body)
+		}
+	}
+
+	if vary := headers.Values("Vary"); !slices.Contains(vary, "Accept-Language") {
+		t.Errorf("want Vary to contain Accept-Language; got %q", vary)
+	}
+
+	if lang := headers.Get("Content-Language"); lang != "es" {
+		t.Errorf("want Content-Language %q; got %q", "es", lang)
+	}
+
+	// The errors of a badly-formed body are translated too
+	_, _, body = ts.do(t, http.MethodPost, "/v1/movies", `{"title": "Moana", "rating": "PG"}`, auth)
+	if want := `el cuerpo contiene la clave desconocida \"rating\"`; !strings.Contains(body, want) {
+		t.Errorf("want body to contain %q; got %s", want, body)
+	}
+
+	_, _, body = ts.do(t, http.MethodPost, "/v1/movies", `{"title": "Moana", "runtime": "soon"}`, auth)
+	if want := "formato de duración no válido"; !strings.Contains(body, want) {
+		t.Errorf("want body to contain %q; got %s", want, body)
+	}
+
+	_, _, body = ts.do(t, http.MethodPut, "/v1/movies/1", "", auth)
+	if want := "el método PUT no está permitido para este recurso"; !strings.Contains(body, want) {
+		t.Errorf("want body to contain %q; got %s", want, body)
+	}
+
+	// English stay the same as before
+	auth.Set("Accept-Language", "en")
+
+	_, headers, body = ts.do(t, http.MethodGet, "/v1/movies/99", "", auth)
+	if want := "the requested resource could not be found"; !strings.Contains(body, want) {
+		t.Errorf("want body to contain %q; got %s", want, body)
+	}
+
+	if lang := headers.Get("Content-Language"); lang != "en" {
+		t.Errorf("want Content-Language %q; got %q", "en", lang)
+	}
+}
+
+func TestPurgeMovieAdminToken(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.adminToken = "s3cret"
+	ts := newTestServer(t, app.routes())
+
+	// The user doesn't have the [movies:purge] permission
+	auth := newTestUser(t, app, "movies:read")
+
+	tests := []struct {
+		name       string
+		headers    http.Header
+		wantStatus int
+	}{
+		{"Admin token", http.Header{"X-Admin-Token": {"s3cret"}}, http.StatusNotFound},
+		{"Wrong admin token", http.Header{"X-Admin-Token": {"wrong"}}, http.StatusForbidden},
+		{"Anonymous", nil, http.StatusUnauthorized},
+		{"Without permission", auth, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The movie doesn't exist, a 404 mean the request was authorized
+			status, _, body := ts.do(t, http.MethodDelete, "/v1/movies/99/purge", "", tt.headers)
+
+			if status != tt.wantStatus {
+				t.Errorf("want status %d; got %d: %s", tt.wantStatus, status, body)
+			}
+		})
+	}
+}
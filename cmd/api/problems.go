@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"greenlight.wolfheros.com/internal/validator"
+)
+
+// [problemTypeBase] is the base URI of the problem types, the type URIs are
+// stable identifiers, clients should compare them instead of the [title] or [detail]
+const problemTypeBase = "https://greenlight.wolfheros.com/problems/"
+
+// [problemType] describe one kind of error in the RFC 9457 problem details format.
+// The [title] is a short summary which doesn't change from occurrence to occurrence.
+type problemType struct {
+	uri   string
+	title string
+}
+
+// Define the problem types returned by the error helpers in [errors.go],
+// the same list is documented in the README
+var (
+	problemBadRequest                 = problemType{problemTypeBase + "bad-request", "Bad request"}
+	problemFailedValidation           = problemType{problemTypeBase + "failed-validation", "Failed validation"}
+	problemNotFound                   = problemType{problemTypeBase + "not-found", "Resource not found"}
+	problemMethodNotAllowed           = problemType{problemTypeBase + "method-not-allowed", "Method not allowed"}
+	problemEditConflict               = problemType{problemTypeBase + "edit-conflict", "Edit conflict"}
+	problemPreconditionFailed         = problemType{problemTypeBase + "precondition-failed", "Precondition failed"}
+	problemInvalidCredentials         = problemType{problemTypeBase + "invalid-credentials", "Invalid credentials"}
+	problemInvalidAuthenticationToken = problemType{problemTypeBase + "invalid-authentication-token", "Invalid authentication token"}
+	problemAuthenticationRequired     = problemType{problemTypeBase + "authentication-required", "Authentication required"}
+	problemInactiveAccount            = problemType{problemTypeBase + "inactive-account", "Inactive account"}
+	problemNotPermitted               = problemType{problemTypeBase + "not-permitted", "Not permitted"}
+	problemRateLimitExceeded          = problemType{problemTypeBase + "rate-limit-exceeded", "Rate limit exceeded"}
+	problemServerError                = problemType{problemTypeBase + "server-error", "Internal server error"}
+)
+
+// [wantsProblemJSON()] report whether the error response should use the
+// [application/problem+json] format: either it is enabled for all responses by the
+// [-error-format=problem] flag, or the client ask for it in the [Accept] header
+func (app *application) wantsProblemJSON(r *http.Request) bool {
+	if app.config.errorFormat == "problem" {
+		return true
+	}
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+
+			if strings.EqualFold(strings.TrimSpace(mediaType), "application/problem+json") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// [problemEnvelope()] build the problem details object. The validation errors
+// are added as the [errors] (first message of each field) and [field_errors] (all of
+// them, with the codes and JSON pointer paths) extension members, the [detail] is a summary then.
+// The [request_id] extension member let the client report the occurrence.
+func (app *application) problemEnvelope(r *http.Request, status int, problem problemType, message any) envelope {
+	env := envelope{
+		"type":     problem.uri,
+		"title":    problem.title,
+		"status":   status,
+		"instance": r.URL.Path,
+	}
+
+	switch message := message.(type) {
+	case *validator.Validator:
+		env["detail"] = app.message(r, "failed_validation", "one or more fields failed validation", nil)
+		env["errors"] = message.Errors
+		env["field_errors"] = message.FieldErrors
+	default:
+		env["detail"] = message
+	}
+
+	if requestID := app.contextGetRequestID(r); requestID != "" {
+		env["request_id"] = requestID
+	}
+
+	return env
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"expvar"
+	"fmt"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
@@ -17,15 +19,81 @@ func (app *application) routes() http.Handler {
 	//Convert the methodAllowedResponse() helper to a [http.Handler]
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
+	// Answer the CORS preflight requests in the automatic OPTIONS handling,
+	// so the allowed methods come from the routes registered below
+	router.GlobalOPTIONS = http.HandlerFunc(app.preflightCORS)
+
+	// [handle()] register the handler and record its URL pattern in the request
+	// context, the metrics are labelled by the pattern instead of the raw path
+	handle := func(method, pattern string, handler http.Handler) {
+		router.Handler(method, pattern, app.recordRoute(pattern, handler))
+	}
+
 	// Register route to the router:
 	// -> Request Methods
 	// -> URL patterns
 	// -> Handler functions
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
+	handle(http.MethodGet, "/v1/healthcheck", http.HandlerFunc(app.healthcheckHandler))
+
+	// Wrap the movie routes with [requirePermission()] middleware,
+	// only the activated user with the permission can access them
+	handle(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
+	handle(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
+	handle(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
+	handle(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
+	handle(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	handle(http.MethodPost, "/v1/movies/:id/restore", app.requirePermission("movies:write", app.restoreMovieHandler))
+	// The deprecated admin token can still purge the movies until the next release
+	handle(http.MethodDelete, "/v1/movies/:id/purge", app.requireAdmin("movies:purge", app.purgeMovieHandler))
+
+	handle(http.MethodPost, "/v1/users", http.HandlerFunc(app.registerUserHandler))
+	handle(http.MethodPut, "/v1/users/activated", http.HandlerFunc(app.activateUserHandler))
+
+	handle(http.MethodPost, "/v1/tokens/authentication", http.HandlerFunc(app.createAuthenticationTokenHandler))
+
+	handler := app.requestID(app.logRequest(app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))))
+
+	// Expose the Prometheus metrics and the [expvar] variables on the public address
+	// only when basic auth is configured, if [-metrics-addr] is set they are served by
+	// a separate server instead. The metrics endpoints bypass the middleware chain, the
+	// [authenticate()] middleware would reject the basic auth [Authorization] header,
+	// and the scrapes shouldn't be counted.
+	if app.config.metrics.addr == "" && app.config.metrics.username != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", app.requestID(app.recoverPanic(app.requireMetricsAuth(app.registry.Handler()))))
+		mux.Handle("GET /debug/vars", app.requestID(app.recoverPanic(app.requireMetricsAuth(varsHandler()))))
+		mux.Handle("/", handler)
 
+		return mux
+	}
 
 	// return router
-	return app.recoverPanic(router)
+	return handler
+}
+
+// [varsHandler()] serve the [expvar] variables in the same format as [expvar.Handler()],
+// except the standard [cmdline] variable, the command-line flags can hold the
+// database DSN, the SMTP and metrics passwords and the cursor secret.
+func varsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		fmt.Fprintf(w, "{\n")
+
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if kv.Key == "cmdline" {
+				return
+			}
+
+			if !first {
+				fmt.Fprintf(w, ",\n")
+			}
+			first = false
+
+			fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+		})
+
+		fmt.Fprintf(w, "\n}\n")
+	})
 }
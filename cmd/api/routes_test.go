@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name       string
+		method     string
+		urlPath    string
+		wantStatus int
+		wantBody   string
+		wantAllow  string
+	}{
+		{
+			name:       "Healthcheck",
+			method:     http.MethodGet,
+			urlPath:    "/v1/healthcheck",
+			wantStatus: http.StatusOK,
+			wantBody:   `"status": "available"`,
+		},
+		{
+			name:       "Not found",
+			method:     http.MethodGet,
+			urlPath:    "/v1/nothing-here",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "the requested resource could not be found",
+		},
+		{
+			name:       "Method not allowed",
+			method:     http.MethodPost,
+			urlPath:    "/v1/healthcheck",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "the POST method is not supported for this resource",
+			wantAllow:  "GET, OPTIONS",
+		},
+		{
+			name:       "Debug vars not public",
+			method:     http.MethodGet,
+			urlPath:    "/debug/vars",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "the requested resource could not be found",
+		},
+		{
+			name:       "Authentication required",
+			method:     http.MethodGet,
+			urlPath:    "/v1/movies",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "you must be authenticated to access this resource",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, headers, body := ts.do(t, tt.method, tt.urlPath, "", nil)
+
+			if status != tt.wantStatus {
+				t.Errorf("want status %d; got %d", tt.wantStatus, status)
+			}
+
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+
+			if tt.wantAllow != "" && headers.Get("Allow") != tt.wantAllow {
+				t.Errorf("want Allow header %q; got %q", tt.wantAllow, headers.Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestDebugVars(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.metrics.username = "prometheus"
+	app.config.metrics.password = "pa55word"
+	ts := newTestServer(t, app.routes())
+
+	status, _, _ := ts.get(t, "/debug/vars")
+	if status != http.StatusUnauthorized {
+		t.Errorf("want status %d without credentials; got %d", http.StatusUnauthorized, status)
+	}
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("prometheus:pa55word")))
+
+	status, _, body := ts.do(t, http.MethodGet, "/debug/vars", "", headers)
+	if status != http.StatusOK {
+		t.Fatalf("want status %d; got %d: %s", http.StatusOK, status, body)
+	}
+
+	var vars map[string]json.RawMessage
+	err := json.Unmarshal([]byte(body), &vars)
+	if err != nil {
+		t.Fatalf("invalid JSON: %v: %s", err, body)
+	}
+
+	// The command-line flags can hold secrets
+	if _, found := vars["cmdline"]; found {
+		t.Error("want no cmdline variable")
+	}
+
+	if _, found := vars["memstats"]; !found {
+		t.Error("want the memstats variable")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// [serve()] start the HTTP server and shut it down gracefully when a
+// SIGINT or SIGTERM signal is received: stop accepting new connections,
+// wait the in-flight requests and the background goroutines to finish.
+func (app *application) serve() error {
+	// Declare a Http server listen on the port provide in the config
+	// contain, time out, and log message
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
+
+	// Serve the Prometheus metrics on a separate address if it is configured,
+	// so it can be kept off the public network
+	var metricsSrv *http.Server
+	if app.config.metrics.addr != "" {
+		var (
+			handler http.Handler = app.registry.Handler()
+			vars    http.Handler = varsHandler()
+		)
+		if app.config.metrics.username != "" {
+			handler = app.requireMetricsAuth(handler)
+			vars = app.requireMetricsAuth(vars)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", handler)
+		mux.Handle("GET /debug/vars", vars)
+
+		metricsSrv = &http.Server{
+			Addr:         app.config.metrics.addr,
+			Handler:      mux,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		}
+
+		go func() {
+			app.logger.Info("starting metrics server", "addr", metricsSrv.Addr)
+
+			err := metricsSrv.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error(err.Error(), "addr", metricsSrv.Addr)
+			}
+		}()
+	}
+
+	// [shutdownError] channel receive any error returned by [Shutdown()]
+	shutdownError := make(chan error)
+
+	// Start a background goroutine to catch the signals
+	go func() {
+		quit := make(chan os.Signal, 1)
+
+		// Listen for SIGINT and SIGTERM signals and relay them to the [quit] channel
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+		// Block until a signal is received
+		s := <-quit
+
+		app.logger.Info("shutting down server", "signal", s.String())
+
+		// Give the in-flight requests and background tasks the deadline to complete
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+		defer cancel()
+
+		// [Shutdown()] return nil once all the in-flight requests are completed,
+		// or an error if the deadline is exceeded
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		if metricsSrv != nil {
+			err = metricsSrv.Shutdown(ctx)
+			if err != nil {
+				shutdownError <- err
+				return
+			}
+		}
+
+		app.logger.Info("completing background tasks", "addr", srv.Addr)
+
+		// Wait the background goroutines in the same deadline
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			shutdownError <- nil
+		case <-ctx.Done():
+			shutdownError <- fmt.Errorf("background tasks: %w", ctx.Err())
+		}
+	}()
+
+	// Evict the stale rate limiters in the background until the server is stopped
+	stopEviction := make(chan struct{})
+	defer close(stopEviction)
+
+	go app.limiters.evict(stopEviction)
+
+	app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.env)
+
+	// [ListenAndServe()] return [http.ErrServerClosed] immediately once [Shutdown()]
+	// is called, it is the signal of graceful shutdown, not an error
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// Wait the result of [Shutdown()] from the goroutine
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server", "addr", srv.Addr)
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"greenlight.wolfheros.com/internal/data"
+)
+
+// [newTestApplication()] return an application backed by the in-memory models,
+// the log entries are discarded and the rate limiter is disabled
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	var cfg config
+	cfg.env = "testing"
+	cfg.errorFormat = "json"
+	cfg.cursor.secret = "test-cursor-secret"
+	cfg.cursor.maxAge = time.Hour
+	cfg.defaultPermissions = []string{"movies:read"}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	app := newApplication(cfg, logger, data.NewMemoryModels())
+	app.mailer = &testMailer{}
+
+	return app
+}
+
+// [testMailer] record the emails instead of sending them
+type testMailer struct {
+	mu   sync.Mutex
+	sent []testEmail
+}
+
+type testEmail struct {
+	recipient    string
+	templateFile string
+	data         any
+}
+
+func (m *testMailer) Send(recipient, templateFile string, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, testEmail{recipient: recipient, templateFile: templateFile, data: data})
+	return nil
+}
+
+// [sentEmails()] wait for the background goroutines, and return the emails sent by the application
+func sentEmails(t *testing.T, app *application) []testEmail {
+	t.Helper()
+
+	app.wg.Wait()
+
+	m := app.mailer.(*testMailer)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.sent)
+}
+
+// [newTestUser()] create an activated user with the permissions,
+// and return the [Authorization] header of a new authentication token
+func newTestUser(t *testing.T, app *application, permissions ...string) http.Header {
+	t.Helper()
+
+	user := &data.User{Name: "Alice", Email: "alice@example.com", Activated: true}
+
+	err := user.Password.Set("pa55word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.models.Permissions.AddForUser(user.ID, permissions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := app.models.Tokens.New(user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return http.Header{"Authorization": {"Bearer " + token.Plaintext}}
+}
+
+// [testServer] wrap a [httptest.Server], with helpers to send the requests
+type testServer struct {
+	*httptest.Server
+}
+
+// [newTestServer()] start a test server for the handler, it is closed when the test finish
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	return &testServer{ts}
+}
+
+// [do()] send a request to the test server, return the status code, the headers and the body
+func (ts *testServer) do(t *testing.T, method, urlPath string, body string, headers http.Header) (int, http.Header, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(b))
+}
+
+// [get()] send a GET request to the test server
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	t.Helper()
+	return ts.do(t, http.MethodGet, urlPath, "", nil)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRegisterUserValidation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name       string
+		password   string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Too long password",
+			password:   strings.Repeat("a1", 36) + "a",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `"password": "must not be more than 72 bytes long"`,
+		},
+		{
+			name:       "Too short password",
+			password:   "pa55",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `"password": "must be at least 8 bytes long"`,
+		},
+		{
+			name:       "Valid",
+			password:   strings.Repeat("a1", 36),
+			wantStatus: http.StatusAccepted,
+			wantBody:   `"email": "alice@example.com"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"name": "Alice", "email": "alice@example.com", "password": %q}`, tt.password)
+
+			status, _, rsBody := ts.do(t, http.MethodPost, "/v1/users", body, nil)
+
+			if status != tt.wantStatus {
+				t.Fatalf("want status %d; got %d: %s", tt.wantStatus, status, rsBody)
+			}
+
+			if !strings.Contains(rsBody, tt.wantBody) {
+				t.Errorf("want body to contain %s; got %s", tt.wantBody, rsBody)
+			}
+		})
+	}
+
+	// Only the valid registration queue the welcome email, with the activation token
+	emails := sentEmails(t, app)
+	if len(emails) != 1 {
+		t.Fatalf("want 1 email; got %d", len(emails))
+	}
+
+	email := emails[0]
+	if email.recipient != "alice@example.com" || email.templateFile != "user_welcome.tmpl" {
+		t.Errorf("unexpected email: %+v", email)
+	}
+
+	data, _ := email.data.(map[string]any)
+	if token, _ := data["activationToken"].(string); len(token) != 26 {
+		t.Errorf("want a 26 bytes activation token; got %q", token)
+	}
+}
+
+func TestDemoUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	err := app.createDemoUser("demo@example.com", "short")
+	if err == nil {
+		t.Fatal("want an error for an invalid password")
+	}
+
+	err = app.createDemoUser("demo@example.com", "pa55word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+
+	// The demo user can authenticate without being activated by email
+	status, _, body := ts.do(t, http.MethodPost, "/v1/tokens/authentication", `{"email": "demo@example.com", "password": "pa55word1"}`, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("want status %d; got %d: %s", http.StatusCreated, status, body)
+	}
+
+	var rs struct {
+		AuthenticationToken struct {
+			Token string `json:"token"`
+		} `json:"authentication_token"`
+	}
+	err = json.Unmarshal([]byte(body), &rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := http.Header{"Authorization": {"Bearer " + rs.AuthenticationToken.Token}}
+
+	// And use all the movie routes
+	requests := []struct {
+		method     string
+		urlPath    string
+		body       string
+		wantStatus int
+	}{
+		{http.MethodPost, "/v1/movies", `{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": ["animation"]}`, http.StatusCreated},
+		{http.MethodGet, "/v1/movies/1", "", http.StatusOK},
+		{http.MethodDelete, "/v1/movies/1", "", http.StatusOK},
+		{http.MethodDelete, "/v1/movies/1/purge", "", http.StatusOK},
+	}
+
+	for _, rq := range requests {
+		status, _, body := ts.do(t, rq.method, rq.urlPath, rq.body, auth)
+		if status != rq.wantStatus {
+			t.Errorf("%s %s: want status %d; got %d: %s", rq.method, rq.urlPath, rq.wantStatus, status, body)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// [ErrInvalidCursor] is returned when a cursor couldn't be decoded, has been
+// tampered with or is too old to be used
+var ErrInvalidCursor = errors.New("invalid or expired cursor")
+
+// [Cursor] struct hold the position of a keyset (cursor) pagination, it is
+// the sort key value and id of the last (or first) row of a page.
+// The [Filter] field is a fingerprint of the title, genres, search and sort parameters,
+// so the cursor can only be used with the same listing it came from.
+type Cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int64  `json:"i"`
+	Before   bool   `json:"b,omitempty"`
+	Filter   string `json:"f"`
+	IssuedAt int64  `json:"t"`
+}
+
+// [Encode()] convert the cursor to an opaque string: the base64 encoded JSON payload
+// followed by a HMAC-SHA256 signature, so any change of the payload can be detected.
+func (c Cursor) Encode(secret []byte) string {
+	// An error will never happen here, the struct only contain strings, integers and bool
+	js, _ := json.Marshal(c)
+
+	payload := base64.RawURLEncoding.EncodeToString(js)
+
+	return payload + "." + signCursor(payload, secret)
+}
+
+// [DecodeCursor()] verify the signature and the age of an encoded cursor,
+// return [ErrInvalidCursor] if any of the checks failed.
+func DecodeCursor(s string, secret []byte, maxAge time.Duration) (Cursor, error) {
+	var c Cursor
+
+	payload, signature, found := strings.Cut(s, ".")
+	if !found {
+		return c, ErrInvalidCursor
+	}
+
+	// Use [hmac.Equal()] to compare the signature in constant time
+	if !hmac.Equal([]byte(signature), []byte(signCursor(payload, secret))) {
+		return c, ErrInvalidCursor
+	}
+
+	js, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	err = json.Unmarshal(js, &c)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	// Cursor older than [maxAge] is seen as stale
+	if time.Since(time.Unix(c.IssuedAt, 0)) > maxAge {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// [Matches()] return true if the cursor was issued for the same title, genres, search and sort parameters
+func (c Cursor) Matches(title string, genres []string, search string, sort string) bool {
+	return c.Sort == sort && c.Filter == cursorFilter(title, genres, search, sort)
+}
+
+func signCursor(payload string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// [cursorFilter()] return a short fingerprint of the listing parameters
+func cursorFilter(title string, genres []string, search string, sort string) string {
+	sum := sha256.Sum256([]byte(title + "\x00" + strings.Join(genres, ",") + "\x00" + search + "\x00" + sort))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// [newCursor()] create a cursor which point to the given movie
+func newCursor(movie *Movie, title string, genres []string, search string, filters Filters, before bool) *Cursor {
+	var value string
+
+	switch filters.sortColumn() {
+	case "relevance":
+		// Shortest representation which can be parsed back to the same float32
+		value = strconv.FormatFloat(float64(movie.Relevance), 'g', -1, 32)
+	case "title":
+		value = movie.Title
+	case "year":
+		value = strconv.FormatInt(int64(movie.Year), 10)
+	case "runtime":
+		value = strconv.FormatInt(int64(movie.Runtime), 10)
+	default:
+		value = strconv.FormatInt(movie.ID, 10)
+	}
+
+	return &Cursor{
+		Sort:     filters.Sort,
+		Value:    value,
+		ID:       movie.ID,
+		Before:   before,
+		Filter:   cursorFilter(title, genres, search, filters.Sort),
+		IssuedAt: time.Now().Unix(),
+	}
+}
+
+// [offsetMetadata()] calculate the pagination metadata of an offset page, the
+// cursors are offered as well, so client can switch to keyset pagination from any page
+func offsetMetadata(movies []*Movie, totalRecords int, title string, genres []string, search string, filters Filters) Metadata {
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	if len(movies) > 0 {
+		if filters.Page < metadata.LastPage {
+			metadata.Next = newCursor(movies[len(movies)-1], title, genres, search, filters, false)
+		}
+		if filters.Page > 1 {
+			metadata.Prev = newCursor(movies[0], title, genres, search, filters, true)
+		}
+	}
+
+	return metadata
+}
+
+// [keysetMetadata()] take the rows fetched after (or before) the cursor, which contain
+// one more row than the page size if there is another page. Return the rows of the page
+// in the sort order, and the pagination metadata with the next and previous cursors.
+func keysetMetadata(movies []*Movie, title string, genres []string, search string, filters Filters) ([]*Movie, Metadata) {
+	hasMore := len(movies) > filters.limit()
+	if hasMore {
+		movies = movies[:filters.limit()]
+	}
+
+	// Walking backward, the rows are in the reverse order
+	if filters.Cursor.Before {
+		slices.Reverse(movies)
+	}
+
+	metadata := Metadata{PageSize: filters.PageSize}
+
+	if len(movies) > 0 {
+		first, last := movies[0], movies[len(movies)-1]
+
+		// There is always a page on the side the cursor came from
+		if !filters.Cursor.Before || hasMore {
+			metadata.Prev = newCursor(first, title, genres, search, filters, true)
+		}
+		if filters.Cursor.Before || hasMore {
+			metadata.Next = newCursor(last, title, genres, search, filters, false)
+		}
+	}
+
+	return movies, metadata
+}
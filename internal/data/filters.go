@@ -0,0 +1,112 @@
+package data
+
+import (
+	"math"
+	"strings"
+
+	"greenlight.wolfheros.com/internal/validator"
+)
+
+// [Filters] struct hold the pagination and sorting parameters
+// from the query string, [SortSafelist] contain the supported sort values.
+// When [Cursor] is not nil, keyset pagination is used instead of [Page]
+type Filters struct {
+	Page         int
+	PageSize     int
+	Sort         string
+	SortSafelist []string
+	Cursor       *Cursor
+}
+
+// Check the page, page_size and sort parameters
+func ValidateFilters(v *validator.Validator, f Filters) {
+	// page and page_size must be reasonable values, the codes are specific to them
+	// because the messages are not the ones of the generic [min] and [max] codes
+	v.CheckCode(f.Page > 0, "page", "page_min", "must be greater than zero", "min", 1)
+	v.CheckCode(f.Page <= 10_000_000, "page", "page_max", "must be a maximum of 10 million", "max", 10_000_000)
+	v.CheckCode(f.PageSize > 0, "page_size", "page_size_min", "must be greater than zero", "min", 1)
+	v.CheckCode(f.PageSize <= 100, "page_size", "page_size_max", "must be a maximum of 100", "max", 100)
+
+	// sort parameter must match a value in the safelist
+	v.CheckCode(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid_sort", "invalid sort value", "values", f.SortSafelist)
+}
+
+// [sortColumn()] return the column name from the [Sort] field, the leading
+// hyphen (if exists) will be removed. It will panic if the value is not in the safelist,
+// this is a sanity check to prevent SQL injection, the value should have been validated.
+func (f Filters) sortColumn() string {
+	for _, safeValue := range f.SortSafelist {
+		if f.Sort == safeValue {
+			return strings.TrimPrefix(f.Sort, "-")
+		}
+	}
+
+	panic("unsafe sort parameter: " + f.Sort)
+}
+
+// [sortDirection()] return "ASC" or "DESC" depend on the prefix of [Sort] field
+func (f Filters) sortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// [keysetComparison()] return the comparison operator and the order direction
+// used by keyset pagination, [Before] cursor walk the rows in the reverse order.
+func (f Filters) keysetComparison() (string, string) {
+	forward := f.Cursor == nil || !f.Cursor.Before
+
+	switch {
+	case f.sortDirection() == "ASC" && forward:
+		return ">", "ASC"
+	case f.sortDirection() == "ASC":
+		return "<", "DESC"
+	case forward:
+		return "<", "DESC"
+	default:
+		return ">", "ASC"
+	}
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// [Metadata] struct hold the pagination information which will be
+// included in the response envelope.
+// [Next] and [Prev] are the raw cursors, they have to be signed and
+// encoded into [NextCursor] and [PrevCursor] before sending to client.
+type Metadata struct {
+	CurrentPage  int     `json:"current_page,omitempty"`
+	PageSize     int     `json:"page_size,omitempty"`
+	FirstPage    int     `json:"first_page,omitempty"`
+	LastPage     int     `json:"last_page,omitempty"`
+	TotalRecords int     `json:"total_records,omitempty"`
+	NextCursor   string  `json:"next_cursor,omitempty"`
+	PrevCursor   string  `json:"prev_cursor,omitempty"`
+	Next         *Cursor `json:"-"`
+	Prev         *Cursor `json:"-"`
+}
+
+// [calculateMetadata()] calculate the pagination metadata values from the
+// total number of records, current page and page size.
+// If there is no record, return an empty [Metadata] struct
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+}
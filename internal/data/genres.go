@@ -0,0 +1,31 @@
+package data
+
+import (
+	"database/sql/driver"
+
+	"github.com/lib/pq"
+)
+
+// [Genres] is the list of movie genres, stored in a PostgreSQL text[] column.
+// It implement [sql.Scanner] and [driver.Valuer] by the [pq.StringArray] type,
+// so it can be scanned and passed as query argument without the [pq.Array()] adapter
+type Genres []string
+
+// Implement the [sql.Scanner] interface, a NULL array become a nil slice
+func (g *Genres) Scan(src any) error {
+	var a pq.StringArray
+
+	err := a.Scan(src)
+	if err != nil {
+		return err
+	}
+
+	*g = Genres(a)
+
+	return nil
+}
+
+// Implement the [driver.Valuer] interface, a nil slice is stored as NULL
+func (g Genres) Value() (driver.Value, error) {
+	return pq.StringArray(g).Value()
+}
@@ -0,0 +1,30 @@
+package data
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGenresRoundTrip(t *testing.T) {
+	for _, genres := range []Genres{nil, {}, {"drama"}, {"sci-fi", "action, adventure", `"quoted"`}} {
+		value, err := genres.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The driver return the text[] value as []byte
+		if s, ok := value.(string); ok {
+			value = []byte(s)
+		}
+
+		var got Genres
+		err = got.Scan(value)
+		if err != nil {
+			t.Fatalf("scan %v: %v", value, err)
+		}
+
+		if !slices.Equal(got, genres) || (got == nil) != (genres == nil) {
+			t.Errorf("want %#v; got %#v", genres, got)
+		}
+	}
+}
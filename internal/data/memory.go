@@ -0,0 +1,584 @@
+package data
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// [memoryPermissionCodes] are the permission codes inserted by the
+// [000007_add_permissions] migration, [AddForUser()] ignore any other code
+var memoryPermissionCodes = []string{"movies:read", "movies:write", "movies:purge"}
+
+// [memoryStore] hold all the records of the in-memory models, the models share
+// one store (and one mutex) because the users, tokens and permissions refer to each other
+type memoryStore struct {
+	mu sync.RWMutex
+
+	movies      map[int64]*memoryMovie
+	lastMovieID int64
+
+	users      map[int64]*User
+	lastUserID int64
+
+	tokens      map[string]*Token
+	permissions map[int64]Permissions
+}
+
+// [memoryMovie] is a stored movie, [deletedAt] is set when the movie is soft-deleted
+type memoryMovie struct {
+	movie     Movie
+	deletedAt *time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		movies:      make(map[int64]*memoryMovie),
+		users:       make(map[int64]*User),
+		tokens:      make(map[string]*Token),
+		permissions: make(map[int64]Permissions),
+	}
+}
+
+// [copyMovie()] return a copy of the movie, the genres slice is cloned too,
+// so the caller can't change the stored record by accident
+func copyMovie(movie Movie) *Movie {
+	movie.Genres = slices.Clone(movie.Genres)
+	return &movie
+}
+
+// [MemoryMovieModel] is the in-memory implementation of [MovieRepository],
+// it follow the same semantics as [MovieModel]: the version check of [Update()],
+// soft-delete, [ErrRecordNotFound], filtering, sorting and pagination.
+type MemoryMovieModel struct {
+	store *memoryStore
+}
+
+func (m *MemoryMovieModel) Insert(movie *Movie) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.lastMovieID++
+
+	movie.ID = m.store.lastMovieID
+	// The [created_at] column is a timestamp(0), it doesn't keep the fractional seconds
+	movie.CreatedAt = time.Now().Truncate(time.Second)
+	movie.Version = 1
+
+	m.store.movies[movie.ID] = &memoryMovie{movie: *copyMovie(*movie)}
+
+	return nil
+}
+
+func (m *MemoryMovieModel) Get(id int64) (*Movie, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	stored, found := m.store.movies[id]
+	if !found || stored.deletedAt != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyMovie(stored.movie), nil
+}
+
+// [GetAll()] filter the movies like the SQL query does: case-insensitive partial match
+// on the title, all the genres must be present. The full-text search is an approximation
+// of the PostgreSQL one, every search word must match the beginning of a word in the title.
+func (m *MemoryMovieModel) GetAll(title string, genres []string, search string, filters Filters) ([]*Movie, Metadata, error) {
+	m.store.mu.RLock()
+
+	var movies []*Movie
+
+	for _, stored := range m.store.movies {
+		movie := stored.movie
+
+		if stored.deletedAt != nil {
+			continue
+		}
+
+		if title != "" && !strings.Contains(strings.ToLower(movie.Title), strings.ToLower(title)) {
+			continue
+		}
+
+		if !containsAll(movie.Genres, genres) {
+			continue
+		}
+
+		if search != "" {
+			relevance, headline, ok := memorySearch(movie.Title, search)
+			if !ok {
+				continue
+			}
+
+			movie.Relevance = relevance
+			movie.Headline = headline
+		}
+
+		movies = append(movies, copyMovie(movie))
+	}
+
+	m.store.mu.RUnlock()
+
+	column := filters.sortColumn()
+
+	if filters.Cursor != nil {
+		return m.getAllByCursor(movies, column, title, genres, search, filters)
+	}
+
+	slices.SortFunc(movies, func(a, b *Movie) int {
+		c := compareMovies(a, b, column)
+		if filters.sortDirection() == "DESC" {
+			return -c
+		}
+		return c
+	})
+
+	totalRecords := len(movies)
+
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+	movies = movies[start:end]
+
+	metadata := offsetMetadata(movies, totalRecords, title, genres, search, filters)
+
+	return movies, metadata, nil
+}
+
+// [getAllByCursor()] select the movies after (or before) the cursor position,
+// in the same way as the row comparison of the SQL query
+func (m *MemoryMovieModel) getAllByCursor(movies []*Movie, column string, title string, genres []string, search string, filters Filters) ([]*Movie, Metadata, error) {
+	position, err := cursorMovie(filters.Cursor, column)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	comparison, direction := filters.keysetComparison()
+
+	movies = slices.DeleteFunc(movies, func(movie *Movie) bool {
+		c := compareMovies(movie, position, column)
+		if comparison == ">" {
+			return c <= 0
+		}
+		return c >= 0
+	})
+
+	slices.SortFunc(movies, func(a, b *Movie) int {
+		c := compareMovies(a, b, column)
+		if direction == "DESC" {
+			return -c
+		}
+		return c
+	})
+
+	// Keep one more row to find out whether there is another page
+	movies = movies[:min(len(movies), filters.limit()+1)]
+
+	movies, metadata := keysetMetadata(movies, title, genres, search, filters)
+
+	return movies, metadata, nil
+}
+
+func (m *MemoryMovieModel) Update(movie *Movie) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	// The record must exist, not deleted, and still have the same version
+	stored, found := m.store.movies[movie.ID]
+	if !found || stored.deletedAt != nil || stored.movie.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	movie.Version++
+
+	stored.movie.Title = movie.Title
+	stored.movie.Year = movie.Year
+	stored.movie.Runtime = movie.Runtime
+	stored.movie.Genres = slices.Clone(movie.Genres)
+	stored.movie.Version = movie.Version
+
+	return nil
+}
+
+func (m *MemoryMovieModel) Delete(id int64, version int32) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, found := m.store.movies[id]
+	if !found || stored.deletedAt != nil || stored.movie.Version != version {
+		return ErrEditConflict
+	}
+
+	now := time.Now()
+	stored.deletedAt = &now
+	stored.movie.Version++
+
+	return nil
+}
+
+func (m *MemoryMovieModel) Restore(id int64) (*Movie, error) {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, found := m.store.movies[id]
+	if !found || stored.deletedAt == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	stored.deletedAt = nil
+	stored.movie.Version++
+
+	return copyMovie(stored.movie), nil
+}
+
+func (m *MemoryMovieModel) Purge(id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, found := m.store.movies[id]; !found {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.movies, id)
+
+	return nil
+}
+
+// [containsAll()] report whether [values] contain all the [wanted] values,
+// like the [@>] array operator of PostgreSQL
+func containsAll(values, wanted []string) bool {
+	for _, w := range wanted {
+		if !slices.Contains(values, w) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// [compareMovies()] compare two movies by the sort column, then by the id
+func compareMovies(a, b *Movie, column string) int {
+	var c int
+
+	switch column {
+	case "title":
+		c = cmp.Compare(a.Title, b.Title)
+	case "year":
+		c = cmp.Compare(a.Year, b.Year)
+	case "runtime":
+		c = cmp.Compare(a.Runtime, b.Runtime)
+	case "relevance":
+		c = cmp.Compare(a.Relevance, b.Relevance)
+	}
+
+	if c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// [cursorMovie()] convert the cursor position back to a [Movie], which
+// can be compared with the other movies by [compareMovies()]
+func cursorMovie(cursor *Cursor, column string) (*Movie, error) {
+	movie := &Movie{ID: cursor.ID}
+
+	switch column {
+	case "title":
+		movie.Title = cursor.Value
+	case "year", "runtime":
+		i, err := strconv.ParseInt(cursor.Value, 10, 32)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		movie.Year = int32(i)
+		movie.Runtime = Runtime(i)
+	case "relevance":
+		f, err := strconv.ParseFloat(cursor.Value, 32)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		movie.Relevance = float32(f)
+	}
+
+	return movie, nil
+}
+
+// [memorySearch()] match the title against the search words. Every word must match
+// the beginning of a title word (a leading "-" exclude the word instead). The relevance
+// is the share of the title words that matched, and the headline wrap the matched
+// words in <b></b> like [ts_headline()] does.
+func memorySearch(title, search string) (float32, string, bool) {
+	isSeparator := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-'
+	}
+
+	var include, exclude []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(search), isSeparator) {
+		if strings.HasPrefix(word, "-") {
+			exclude = append(exclude, strings.TrimLeft(word, "-"))
+		} else {
+			include = append(include, word)
+		}
+	}
+
+	titleWords := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	matchesWord := func(word string) bool {
+		for _, titleWord := range titleWords {
+			if strings.HasPrefix(strings.ToLower(titleWord), word) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, word := range exclude {
+		if word != "" && matchesWord(word) {
+			return 0, "", false
+		}
+	}
+
+	if len(include) == 0 {
+		return 0, "", false
+	}
+
+	for _, word := range include {
+		if !matchesWord(word) {
+			return 0, "", false
+		}
+	}
+
+	// Highlight the matched words of the title, and count them for the relevance
+	var (
+		headline strings.Builder
+		matched  int
+	)
+
+	rest := title
+	for _, titleWord := range titleWords {
+		i := strings.Index(rest, titleWord)
+		headline.WriteString(rest[:i])
+
+		isMatch := slices.ContainsFunc(include, func(word string) bool {
+			return strings.HasPrefix(strings.ToLower(titleWord), word)
+		})
+
+		if isMatch {
+			matched++
+			headline.WriteString("<b>" + titleWord + "</b>")
+		} else {
+			headline.WriteString(titleWord)
+		}
+
+		rest = rest[i+len(titleWord):]
+	}
+	headline.WriteString(rest)
+
+	return float32(matched) / float32(len(titleWords)), headline.String(), true
+}
+
+// [MemoryPermissionModel] is the in-memory implementation of [PermissionRepository]
+type MemoryPermissionModel struct {
+	store *memoryStore
+}
+
+func (m *MemoryPermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	return slices.Clone(m.store.permissions[userID]), nil
+}
+
+func (m *MemoryPermissionModel) AddForUser(userID int64, codes ...string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.addPermissions(userID, codes)
+
+	return nil
+}
+
+// [addPermissions()] grant the known permission codes to the user, the caller must hold the lock
+func (s *memoryStore) addPermissions(userID int64, codes []string) {
+	for _, code := range codes {
+		if slices.Contains(memoryPermissionCodes, code) && !s.permissions[userID].Include(code) {
+			s.permissions[userID] = append(s.permissions[userID], code)
+		}
+	}
+}
+
+// [MemoryTokenModel] is the in-memory implementation of [TokenRepository]
+type MemoryTokenModel struct {
+	store *memoryStore
+}
+
+func (m *MemoryTokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m *MemoryTokenModel) Insert(token *Token) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored := *token
+	stored.Plaintext = ""
+
+	m.store.tokens[string(token.Hash)] = &stored
+
+	return nil
+}
+
+func (m *MemoryTokenModel) DeleteAllForUser(scope string, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	for hash, token := range m.store.tokens {
+		if token.Scope == scope && token.UserID == userID {
+			delete(m.store.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+// [MemoryUserModel] is the in-memory implementation of [UserRepository],
+// the email addresses are compared case-insensitively like the [citext] column
+type MemoryUserModel struct {
+	store *memoryStore
+}
+
+// [emailTaken()] report whether another user already use the email address,
+// the caller must hold the lock
+func (m *MemoryUserModel) emailTaken(email string, exceptID int64) bool {
+	for _, user := range m.store.users {
+		if user.ID != exceptID && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *MemoryUserModel) Insert(user *User, permissions ...string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return m.insert(user, permissions)
+}
+
+// [Register()] insert the user and its activation token under the same lock,
+// the token is generated first so nothing is stored if it fail
+func (m *MemoryUserModel) Register(user *User, activationTTL time.Duration, permissions ...string) (*Token, error) {
+	token, err := generateToken(0, activationTTL, ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	err = m.insert(user, permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	token.UserID = user.ID
+
+	stored := *token
+	stored.Plaintext = ""
+	m.store.tokens[string(token.Hash)] = &stored
+
+	return token, nil
+}
+
+// [insert()] store the user and grant the permissions, the caller must hold the lock
+func (m *MemoryUserModel) insert(user *User, permissions []string) error {
+	if m.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+
+	m.store.lastUserID++
+
+	user.ID = m.store.lastUserID
+	user.CreatedAt = time.Now().Truncate(time.Second)
+	user.Version = 1
+
+	stored := *user
+	stored.Password = password{hash: slices.Clone(user.Password.hash)}
+
+	m.store.users[user.ID] = &stored
+	m.store.addPermissions(user.ID, permissions)
+
+	return nil
+}
+
+func (m *MemoryUserModel) GetByEmail(email string) (*User, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for _, user := range m.store.users {
+		if strings.EqualFold(user.Email, email) {
+			found := *user
+			return &found, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m *MemoryUserModel) Update(user *User) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.emailTaken(user.Email, user.ID) {
+		return ErrDuplicateEmail
+	}
+
+	stored, found := m.store.users[user.ID]
+	if !found || stored.Version != user.Version {
+		return ErrEditConflict
+	}
+
+	user.Version++
+
+	stored.Name = user.Name
+	stored.Email = user.Email
+	stored.Password = password{hash: slices.Clone(user.Password.hash)}
+	stored.Activated = user.Activated
+	stored.Version = user.Version
+
+	return nil
+}
+
+func (m *MemoryUserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := hashToken(tokenPlaintext)
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	token, found := m.store.tokens[string(tokenHash[:])]
+	if !found || token.Scope != tokenScope || !token.Expiry.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+
+	user, found := m.store.users[token.UserID]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	result := *user
+	return &result, nil
+}
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemoryMovieDelete(t *testing.T) {
+	models := NewMemoryModels()
+
+	movie := &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: Genres{"animation"}}
+
+	err := models.Movies.Insert(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another request update the movie after the version has been read
+	version := movie.Version
+
+	err = models.Movies.Update(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Movies.Delete(movie.ID, version)
+	if !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("want ErrEditConflict for a stale version; got %v", err)
+	}
+
+	err = models.Movies.Delete(movie.ID, movie.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.Movies.Get(movie.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("want ErrRecordNotFound after delete; got %v", err)
+	}
+
+	err = models.Movies.Delete(movie.ID, movie.Version+1)
+	if !errors.Is(err, ErrEditConflict) {
+		t.Errorf("want ErrEditConflict for a deleted movie; got %v", err)
+	}
+}
+
+func TestMemoryUserInsertPermissions(t *testing.T) {
+	models := NewMemoryModels()
+
+	user := &User{Name: "Alice", Email: "alice@example.com"}
+
+	err := models.Users.Insert(user, "movies:read", "no:such")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permissions, err := models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(permissions, Permissions{"movies:read"}) {
+		t.Errorf("want permissions [movies:read]; got %v", permissions)
+	}
+
+	// A duplicate email doesn't create a user, so nothing is granted
+	err = models.Users.Insert(&User{Name: "Bob", Email: "ALICE@example.com"}, "movies:write")
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("want ErrDuplicateEmail; got %v", err)
+	}
+
+	permissions, err = models.Permissions.GetAllForUser(user.ID + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions) != 0 {
+		t.Errorf("want no permissions; got %v", permissions)
+	}
+}
+
+func TestMemoryUserRegister(t *testing.T) {
+	models := NewMemoryModels()
+
+	user := &User{Name: "Alice", Email: "alice@example.com"}
+
+	token, err := models.Users.Register(user, time.Hour, "movies:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.UserID != user.ID || token.Scope != ScopeActivation {
+		t.Errorf("unexpected token: %+v", token)
+	}
+
+	got, err := models.Users.GetForToken(ScopeActivation, token.Plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("want user %d for the token; got %d", user.ID, got.ID)
+	}
+
+	// A duplicate email store neither the user nor a token
+	_, err = models.Users.Register(&User{Name: "Bob", Email: "alice@example.com"}, time.Hour)
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("want ErrDuplicateEmail; got %v", err)
+	}
+}
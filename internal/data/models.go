@@ -3,21 +3,77 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"time"
 )
 
 //Define a custom Error for [Get()] method
+// [ErrEditConflict] is returned by [Update()] when the record version doesn't match
 var(
 	ErrRecordNotFound = errors.New("record not found")
+	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// [MovieRepository] is implemented by [MovieModel] (PostgreSQL) and
+// [MemoryMovieModel] (in-memory), the handlers only depend on the interface
+type MovieRepository interface {
+	Insert(movie *Movie) error
+	Get(id int64) (*Movie, error)
+	GetAll(title string, genres []string, search string, filters Filters) ([]*Movie, Metadata, error)
+	Update(movie *Movie) error
+	Delete(id int64, version int32) error
+	Restore(id int64) (*Movie, error)
+	Purge(id int64) error
+}
+
+// [PermissionRepository] is implemented by [PermissionModel] and [MemoryPermissionModel]
+type PermissionRepository interface {
+	GetAllForUser(userID int64) (Permissions, error)
+	AddForUser(userID int64, codes ...string) error
+}
+
+// [TokenRepository] is implemented by [TokenModel] and [MemoryTokenModel]
+type TokenRepository interface {
+	New(userID int64, ttl time.Duration, scope string) (*Token, error)
+	Insert(token *Token) error
+	DeleteAllForUser(scope string, userID int64) error
+}
+
+// [UserRepository] is implemented by [UserModel] and [MemoryUserModel]
+type UserRepository interface {
+	Insert(user *User, permissions ...string) error
+	Register(user *User, activationTTL time.Duration, permissions ...string) (*Token, error)
+	GetByEmail(email string) (*User, error)
+	Update(user *User) error
+	GetForToken(tokenScope, tokenPlaintext string) (*User, error)
+}
+
 // Create a Models struct which will wrap all the Models in the future, include MovieModels
 type Models struct{
-	Movies MovieModel
+	Movies      MovieRepository
+	Permissions PermissionRepository
+	Tokens      TokenRepository
+	Users       UserRepository
 }
 
-// Create [Models] instance
+// Create [Models] instance backed by PostgreSQL
 func NewModels(db *sql.DB)Models{
 	return Models{
-		Movies: MovieModel{DB: db},
+		Movies:      MovieModel{DB: db},
+		Permissions: PermissionModel{DB: db},
+		Tokens:      TokenModel{DB: db},
+		Users:       UserModel{DB: db},
 	}
-}
\ No newline at end of file
+}
+
+// Create [Models] instance which keep all the data in memory, nothing is persisted.
+// It doesn't need a database, used for demos and tests.
+func NewMemoryModels() Models {
+	store := newMemoryStore()
+
+	return Models{
+		Movies:      &MemoryMovieModel{store: store},
+		Permissions: &MemoryPermissionModel{store: store},
+		Tokens:      &MemoryTokenModel{store: store},
+		Users:       &MemoryUserModel{store: store},
+	}
+}
@@ -1,7 +1,11 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"greenlight.wolfheros.com/internal/validator"
@@ -28,11 +32,14 @@ The [Movies] struct is store data like this:
 type Movie struct {
 	ID        int64     `json:"id"` 				// struct tags
 	CreatedAt time.Time `json:"-"` 					// use [-] (hyphen) to remove from the result
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"` 	// Add the omitempty directive to remove the value while its [empty], [nil] or [""]
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"` 	//use slice to store multiple value.
+	Title     string    `json:"title" validate:"required,max=500"`
+	Year      int32     `json:"year,omitempty" validate:"required,between=1888:now"` 	// Add the omitempty directive to remove the value while its [empty], [nil] or [""]
+	Runtime   Runtime   `json:"runtime,omitempty" validate:"required,min=1"`
+	Genres    Genres    `json:"genres,omitempty" validate:"required,max=5,unique,dive,required"` 	//use slice to store multiple value.
 	Version   int32     `json:"version"`          	//version start as 1 will increase based on the update operation
+	Relevance float32   `json:"relevance,omitempty"` 	// full-text search rank, only set in search results
+	Headline  string    `json:"headline,omitempty"`  	// title with the matching words highlighted, only set in search results
+
 }
 
 // Define movie models struct to store DB config
@@ -42,37 +49,353 @@ type MovieModel struct{
 
 
 
-// Using [Check()] method to execute each different verification
-// [Check()] first param is [bool] value, it will decide the behaviour of 
-// [Check()] method
+// Check the movie with [validator.Struct()], the errors are
+// keyed by the JSON field names
 func ValidateMovie(v *validator.Validator, movie *Movie){
-	// check title
-	v.Check(movie.Title!="", "title", "must be provided")
-	// check title length less than 500
-	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
-	// check year valid
-	v.Check((movie.Year != 0) && (movie.Year >=1888) && (movie.Year <= int32(time.Now().Year())), "year", "must be valid value, over 1888 but not in the future")
-	// check runtime value
-	v.Check(movie.Runtime > 0, "runtime", "must be provided and must be a positive integer")
-	// check genres value
-	v.Check(movie.Genres != nil && len(movie.Genres) >=1 && len(movie.Genres) <= 5, "genres" , "Must be provided and at least 1 genres less than 5 genres")
-	v.Check(validator.Unique(movie.Genres), "genres", "Must not contain duplicate values")
+	// The checks are declared by the [validate] tags of the [Movie] struct:
+	// title provided and at most 500 bytes, year between 1888 and the current year,
+	// positive runtime, 1 to 5 non-empty genres without duplicate
+	validator.Struct(v, movie)
 }
 
 
 // Add [CRUD] method for databse operation
-func (m MovieModel) Insert(movie *Movie) error{
-	return nil
+
+// [Insert()] method accept a pointer of [Movie] struct, which should contain the data
+// for the new record. The [id], [created_at] and [version] columns are generated by
+// the database, so use [RETURNING] clause to read them back into the struct.
+func (m MovieModel) Insert(movie *Movie) error {
+	query := `
+		INSERT INTO movies (title, year, runtime, genres)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version`
+
+	// [Runtime] and [Genres] implement [driver.Valuer] interface, so
+	// they can be passed as they are, the genres become a PostgreSQL text[] value
+	args := []any{movie.Title, movie.Year, movie.Runtime, movie.Genres}
+
+	// Create a context with 3 seconds timeout deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+}
+
+// [Get()] method fetch a specific record from the movies table by [id]
+func (m MovieModel) Get(id int64) (*Movie, error) {
+	// PostgreSQL bigserial start from 1, so there is no need to query
+	// the database with an id less than 1
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, title, year, runtime, genres, version
+		FROM movies
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// [Runtime] and [Genres] implement [sql.Scanner] interface, so the columns are scanned directly
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		&movie.Genres,
+		&movie.Version,
+	)
+
+	// If there is no matching movie, [Scan()] return [sql.ErrNoRows] error,
+	// convert it to our custom [ErrRecordNotFound] error
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &movie, nil
+}
+
+// [movieListQuery] is the base of the movies listing queries, it select the
+// movies which are not deleted and match the filters:
+// [ILIKE] give a case-insensitive partial match on the title ($1).
+// [genres @> $2] check that the genres column contain all the values in the array.
+// [search @@ q] match the full-text search query ($3), [ts_rank()] give the relevance score.
+// Empty filter values will match all the rows.
+const movieListQuery = `
+	SELECT movies.*, CASE WHEN $3 = '' THEN 0 ELSE ts_rank(search, q) END AS relevance
+	FROM movies, websearch_to_tsquery('english', $3) AS q
+	WHERE deleted_at IS NULL
+	AND (title ILIKE '%' || $1 || '%' OR $1 = '')
+	AND (genres @> $2 OR $2 = '{}')
+	AND (search @@ q OR $3 = '')`
+
+// [movieListColumns] are the columns selected from [movieListQuery],
+// [ts_headline()] highlight the matching words in the title.
+const movieListColumns = `
+	id, created_at, title, year, runtime, genres, version, relevance,
+	CASE WHEN $3 = '' THEN '' ELSE ts_headline('english', title, websearch_to_tsquery('english', $3)) END`
+
+// [GetAll()] method return a list of movies, filtered by [title], [genres] and the
+// full-text [search] query, sorted and paginated by [filters], the pagination [Metadata] is returned too.
+// If [filters.Cursor] is set, the keyset pagination is used.
+func (m MovieModel) GetAll(title string, genres []string, search string, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.Cursor != nil {
+		return m.getAllByCursor(title, genres, search, filters)
+	}
+
+	// [count(*) OVER()] window function return the total number of filtered records.
+	// Sort by [id] as secondary column to make sure the order is always consistent,
+	// it use the same direction as the sort column so the order match the keyset pagination.
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM (%s) AS movies
+		ORDER BY %s %s, id %[4]s
+		LIMIT $4 OFFSET $5`, movieListColumns, movieListQuery, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Escape the [ILIKE] wildcard characters in the title, so they are matched literally
+	args := []any{escapeLike(title), Genres(genres), search, filters.limit(), filters.offset()}
+
+	totalRecords, movies, err := m.queryList(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := offsetMetadata(movies, totalRecords, title, genres, search, filters)
+
+	return movies, metadata, nil
+}
+
+// [getAllByCursor()] return the page of movies right after (or before) the cursor position.
+// The row comparison [(column, id) > (value, id)] can use the index and doesn't
+// need to scan the skipped rows like [OFFSET] does.
+func (m MovieModel) getAllByCursor(title string, genres []string, search string, filters Filters) ([]*Movie, Metadata, error) {
+	comparison, direction := filters.keysetComparison()
+
+	// Fetch one more row to find out whether there is another page.
+	// The total number of records is not counted here, [count(*) OVER()] would
+	// have to visit all the matching rows which is what keyset pagination avoid.
+	query := fmt.Sprintf(`
+		SELECT 0, %s
+		FROM (%s) AS movies
+		WHERE (%[3]s, id) %[4]s ($4, $5)
+		ORDER BY %[3]s %[5]s, id %[5]s
+		LIMIT $6`, movieListColumns, movieListQuery, filters.sortColumn(), comparison, direction)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{escapeLike(title), Genres(genres), search, filters.Cursor.Value, filters.Cursor.ID, filters.limit() + 1}
+
+	_, movies, err := m.queryList(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	movies, metadata := keysetMetadata(movies, title, genres, search, filters)
+
+	return movies, metadata, nil
 }
 
-func (m MovieModel) Get(id int64) error{
+// [queryList()] run a movies listing query and scan the result set, the first
+// column of the query must be the total number of records.
+func (m MovieModel) queryList(ctx context.Context, query string, args ...any) (int, []*Movie, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Make sure the result set is closed before [queryList()] return
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			&movie.Genres,
+			&movie.Version,
+			&movie.Relevance,
+			&movie.Headline,
+		)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	// Retrieve any error that was encountered during the iteration
+	if err = rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	return totalRecords, movies, nil
+}
+
+// [escapeLike()] escape the special characters of [LIKE] / [ILIKE] pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// [Update()] method update a specific record in the movies table,
+// the [version] will be increased by 1 and read back into the struct.
+// The [WHERE] clause also check the [version] (optimistic locking), if the record
+// has been changed by another request since it was read, no rows will match.
+func (m MovieModel) Update(movie *Movie) error {
+	query := `
+		UPDATE movies
+		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING version`
+
+	args := []any{
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		movie.Genres,
+		movie.ID,
+		movie.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// If no matching row could be found, the version has been changed
+	// (or the record deleted), so return [ErrEditConflict] error
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (m MovieModel) Update(movie *Movie) error{
+// [Delete()] method soft-delete a specific record, the row is kept in the movies table
+// and only marked with a [deleted_at] timestamp, so it can be restored later.
+// Soft-deleted records are hidden from [Get()] and [Update()].
+func (m MovieModel) Delete(id int64, version int32) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return err
+	}
+
+	// Check how many rows were affected by the query, if no rows were affected,
+	// the movie has been changed or deleted since the caller read the version
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
 	return nil
 }
 
-func (m MovieModel) Delete(id int64) error{
+// [Restore()] method undo a soft-delete, clear the [deleted_at] timestamp
+// and return the restored record.
+func (m MovieModel) Restore(id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, created_at, title, year, runtime, genres, version`
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		&movie.Genres,
+		&movie.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &movie, nil
+}
+
+// [Purge()] method permanently remove a record from the movies table,
+// no matter it has been soft-deleted or not. This can't be undone.
+func (m MovieModel) Purge(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movies
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -1,24 +1,88 @@
 package data
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
-	"strings"
 )
 
 // Define an error that [UnmarshalJSON()] method can return
 // If unable parse or convert the JSON string
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
+// The runtime formats accepted by [UnmarshalJSON()]:
+// "102 mins" (or "1 min"), ISO 8601 duration "PT1H42M", and "1h42m" (or "1h 42m").
+// Negative runtimes and leading or trailing spaces are rejected
+var (
+	runtimeMinsRX    = regexp.MustCompile(`^(\d+) mins?$`)
+	runtimeISO8601RX = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?$`)
+	runtimeShortRX   = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?$`)
+	// The space is only allowed between the hours and the minutes, so " 42m" and "1h " are rejected
+	runtimeSpacedRX = regexp.MustCompile(`^(\d+)h (\d+)m$`)
+)
+
+// [RuntimeFormat] is how the runtime is rendered in the JSON output
+type RuntimeFormat int
+
+const (
+	RuntimeFormatMins    RuntimeFormat = iota // "102 mins", the default
+	RuntimeFormatISO8601                      // "PT1H42M"
+	RuntimeFormatInteger                      // 102
+)
+
+// [RuntimeFormats] list all the runtime formats
+var RuntimeFormats = []RuntimeFormat{RuntimeFormatMins, RuntimeFormatISO8601, RuntimeFormatInteger}
+
+// [ParseRuntimeFormat()] return the format by its name, such as "iso8601"
+func ParseRuntimeFormat(name string) (RuntimeFormat, bool) {
+	for _, format := range RuntimeFormats {
+		if format.String() == name {
+			return format, true
+		}
+	}
+
+	return RuntimeFormatMins, false
+}
+
+// [String()] return the name of the format, used by the [runtime_format] parameter
+func (f RuntimeFormat) String() string {
+	switch f {
+	case RuntimeFormatISO8601:
+		return "iso8601"
+	case RuntimeFormatInteger:
+		return "integer"
+	default:
+		return "mins"
+	}
+}
+
 // Declare runtime type for custom json output, which mean it
 // need to implement [MarshalJson()] interface [json.Marshaler] method
 type Runtime int32
 
-// implement [json.Marshaler] interface's [MarshalJSON()] method
+// implement [json.Marshaler] interface's [MarshalJSON()] method,
+// use the default "<runtime> mins" format
 func (r Runtime) MarshalJSON() ([]byte, error) {
-	// formating the time with "mins" output the result
-	jsonValue := fmt.Sprintf("%d mins", r)
+	return r.MarshalJSONFormat(RuntimeFormatMins)
+}
+
+// [MarshalJSONFormat()] render the runtime in the format, the integer
+// format is a JSON number, the others are JSON strings
+func (r Runtime) MarshalJSONFormat(format RuntimeFormat) ([]byte, error) {
+	var jsonValue string
+
+	switch format {
+	case RuntimeFormatInteger:
+		return []byte(strconv.FormatInt(int64(r), 10)), nil
+	case RuntimeFormatISO8601:
+		jsonValue = r.iso8601()
+	default:
+		// formating the time with "mins" output the result
+		jsonValue = fmt.Sprintf("%d mins", r)
+	}
 
 	// add surrounding double-quotes to become a valid *JSON string*
 	quotedJSONValue := strconv.Quote(jsonValue)
@@ -27,6 +91,21 @@ func (r Runtime) MarshalJSON() ([]byte, error) {
 	return []byte(quotedJSONValue), nil
 }
 
+// [iso8601()] return the runtime as ISO 8601 duration, such as "PT1H42M",
+// the zero components are left out, except for zero runtime "PT0M"
+func (r Runtime) iso8601() string {
+	hours, minutes := r/60, r%60
+
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("PT%dM", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("PT%dH", hours)
+	default:
+		return fmt.Sprintf("PT%dH%dM", hours, minutes)
+	}
+}
+
 /**
 * When Go decoding some JSON, it will check whether or not there is destination type
 * satisfies the [json.Unmarshaler] interface, which mean implementation of [UnmarshalJSON()]
@@ -37,7 +116,17 @@ func (r Runtime) MarshalJSON() ([]byte, error) {
 // Implement a [UnmarshalJSON()] method on the customer Runtime type so that it satifies the [json.Unmarshaler] interface
 // we need modify the receiver, so we must use a pointer receiver. otherwise it will only modify the copy [Runtime] value
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error{
-	// Incoming JSON value will be a string in the format "<runtime> mins"
+	// A JSON number is the runtime in minutes, it must be an integer
+	if len(jsonValue) > 0 && jsonValue[0] != '"' {
+		i, err := strconv.ParseInt(string(jsonValue), 10, 32)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
+
+		*r = Runtime(i)
+		return nil
+	}
+
 	// First, removing the surrounding double-quotes from this string.
 	// If cant unquote it, return [ErrInvalidRountimeFormat] error
 	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
@@ -45,23 +134,96 @@ func (r *Runtime) UnmarshalJSON(jsonValue []byte) error{
 		return ErrInvalidRuntimeFormat
 	}
 
-	//split the string based on " ", with string and number
-	parts:= strings.Split(unquotedJSONValue, " ")
+	// "<runtime> mins", the unit must be there
+	if parts := runtimeMinsRX.FindStringSubmatch(unquotedJSONValue); parts != nil {
+		i, err := strconv.ParseInt(parts[1], 10, 32)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
+
+		*r = Runtime(i)
+		return nil
+	}
+
+	// "PT1H42M" or "1h42m", at least one of the hours and minutes must be there
+	parts := runtimeISO8601RX.FindStringSubmatch(unquotedJSONValue)
+	if parts == nil {
+		parts = runtimeShortRX.FindStringSubmatch(unquotedJSONValue)
+	}
+	if parts == nil {
+		parts = runtimeSpacedRX.FindStringSubmatch(unquotedJSONValue)
+	}
 
-	// Check the output value which should two parts: "<number>" and "mins"
-	if len(parts) > 2 {
+	if parts == nil || (parts[1] == "" && parts[2] == "") {
 		return ErrInvalidRuntimeFormat
 	}
 
-	// parse the first value to [int32]
-	i, err:= strconv.ParseInt(parts[0], 10, 32)
-	if err!=nil {
+	var minutes int64
+
+	if parts[1] != "" {
+		hours, err := strconv.ParseInt(parts[1], 10, 32)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
+		minutes = hours * 60
+	}
+
+	if parts[2] != "" {
+		i, err := strconv.ParseInt(parts[2], 10, 32)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
+		minutes += i
+	}
+
+	if minutes > math.MaxInt32 {
 		return ErrInvalidRuntimeFormat
 	}
 
 	// here is using pointer,
 	// deference the receiver with a new value
+	*r = Runtime(minutes)
+
+	return nil
+}
+
+// Implement the [sql.Scanner] interface, so the [runtime] column can be scanned
+// into a [Runtime] directly. The value must fit in an int32, NULL is not accepted
+// because the column is NOT NULL.
+func (r *Runtime) Scan(src any) error {
+	var i int64
+
+	switch src := src.(type) {
+	case int64:
+		i = src
+	case []byte:
+		parsed, err := strconv.ParseInt(string(src), 10, 64)
+		if err != nil {
+			return fmt.Errorf("scan runtime: %w", err)
+		}
+		i = parsed
+	case string:
+		parsed, err := strconv.ParseInt(src, 10, 64)
+		if err != nil {
+			return fmt.Errorf("scan runtime: %w", err)
+		}
+		i = parsed
+	case nil:
+		return errors.New("scan runtime: NULL value")
+	default:
+		return fmt.Errorf("scan runtime: unsupported type %T", src)
+	}
+
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return fmt.Errorf("scan runtime: value %d out of range", i)
+	}
+
 	*r = Runtime(i)
 
 	return nil
 }
+
+// Implement the [driver.Valuer] interface, the runtime is stored as integer minutes
+func (r Runtime) Value() (driver.Value, error) {
+	return int64(r), nil
+}
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRuntimeRoundTrip(t *testing.T) {
+	for _, format := range RuntimeFormats {
+		for _, runtime := range []Runtime{0, 1, 60, 102, 2147483647} {
+			js, err := runtime.MarshalJSONFormat(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got Runtime
+			err = json.Unmarshal(js, &got)
+			if err != nil {
+				t.Fatalf("unmarshal %s: %v", js, err)
+			}
+
+			if got != runtime {
+				t.Errorf("want %d; got %d (from %s)", runtime, got, js)
+			}
+		}
+	}
+}
+
+func TestRuntimeMarshalJSON(t *testing.T) {
+	tests := []struct {
+		runtime Runtime
+		format  RuntimeFormat
+		want    string
+	}{
+		{102, RuntimeFormatMins, `"102 mins"`},
+		{102, RuntimeFormatISO8601, `"PT1H42M"`},
+		{120, RuntimeFormatISO8601, `"PT2H"`},
+		{42, RuntimeFormatISO8601, `"PT42M"`},
+		{0, RuntimeFormatISO8601, `"PT0M"`},
+		{102, RuntimeFormatInteger, `102`},
+	}
+
+	for _, tt := range tests {
+		js, err := tt.runtime.MarshalJSONFormat(tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(js) != tt.want {
+			t.Errorf("%d in %s: want %s; got %s", tt.runtime, tt.format, tt.want, js)
+		}
+	}
+
+	// [MarshalJSON()] use the mins format
+	js, err := json.Marshal(Runtime(102))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `"102 mins"`; string(js) != want {
+		t.Errorf("want %s; got %s", want, js)
+	}
+}
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		js   string
+		want Runtime
+	}{
+		{`"102 mins"`, 102},
+		{`"1 min"`, 1},
+		{`102`, 102},
+		{`"PT1H42M"`, 102},
+		{`"PT2H"`, 120},
+		{`"PT42M"`, 42},
+		{`"1h42m"`, 102},
+		{`"1h 42m"`, 102},
+		{`"2h"`, 120},
+		{`"42m"`, 42},
+	}
+
+	for _, tt := range tests {
+		var r Runtime
+
+		err := json.Unmarshal([]byte(tt.js), &r)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.js, err)
+			continue
+		}
+
+		if r != tt.want {
+			t.Errorf("%s: want %d; got %d", tt.js, tt.want, r)
+		}
+	}
+}
+
+func TestRuntimeUnmarshalJSONInvalid(t *testing.T) {
+	tests := []string{
+		`"102"`,
+		`"102 hours"`,
+		`"102 mins long"`,
+		`"one hundred mins"`,
+		`"99999999999 mins"`,
+		`102.5`,
+		`null`,
+		`"PT"`,
+		`"PT1H42M30S"`,
+		`"P1D"`,
+		`""`,
+		`"h"`,
+		`"42m1h"`,
+		`"35791395h"`,
+		`"-5 mins"`,
+		`" 42m"`,
+		`"1h "`,
+		`" 1h42m"`,
+		`"1h  42m"`,
+	}
+
+	for _, js := range tests {
+		var r Runtime
+
+		err := json.Unmarshal([]byte(js), &r)
+		if !errors.Is(err, ErrInvalidRuntimeFormat) {
+			t.Errorf("%s: want ErrInvalidRuntimeFormat; got %v", js, err)
+		}
+	}
+}
+
+func TestRuntimeScan(t *testing.T) {
+	tests := []struct {
+		src     any
+		want    Runtime
+		wantErr bool
+	}{
+		{src: int64(102), want: 102},
+		{src: []byte("102"), want: 102},
+		{src: "102", want: 102},
+		{src: int64(2147483648), wantErr: true},
+		{src: []byte("abc"), wantErr: true},
+		{src: nil, wantErr: true},
+		{src: 1.5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var r Runtime
+
+		err := r.Scan(tt.src)
+
+		switch {
+		case tt.wantErr && err == nil:
+			t.Errorf("%v: want error; got %d", tt.src, r)
+		case !tt.wantErr && err != nil:
+			t.Errorf("%v: unexpected error: %v", tt.src, err)
+		case r != tt.want:
+			t.Errorf("%v: want %d; got %d", tt.src, tt.want, r)
+		}
+	}
+
+	value, err := Runtime(102).Value()
+	if err != nil || value != int64(102) {
+		t.Errorf("want 102; got %v (%v)", value, err)
+	}
+}
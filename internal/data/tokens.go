@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"time"
+
+	"greenlight.wolfheros.com/internal/validator"
+)
+
+// Define constants for the token scope
+const (
+	ScopeActivation     = "activation"
+	ScopeAuthentication = "authentication"
+)
+
+// [Token] struct hold the data of an individual token, only the [Plaintext]
+// will be sent to the client, the [Hash] is stored in the database.
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
+}
+
+// [generateToken()] create a new token with 16 random bytes, which is
+// encoded to a 26 characters base32 string as the plaintext.
+func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token := &Token{
+		UserID: userID,
+		Expiry: time.Now().Add(ttl),
+		Scope:  scope,
+	}
+
+	// Use [crypto/rand] to fill the byte slice with random bytes
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode without the padding character [=]
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	hash := hashToken(token.Plaintext)
+	token.Hash = hash[:]
+
+	return token, nil
+}
+
+// [hashToken()] return the SHA-256 hash of the plaintext token
+func hashToken(plaintext string) [32]byte {
+	return sha256.Sum256([]byte(plaintext))
+}
+
+// Check the plaintext token is provided and exactly 26 bytes long
+func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
+	if tokenPlaintext == "" {
+		v.AddErrorCode("token", "required", "must be provided")
+		return
+	}
+
+	v.CheckCode(len(tokenPlaintext) == 26, "token", "length", "must be 26 bytes long", "length", 26)
+}
+
+// Define token models struct to store DB config
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// [New()] method create a new token and insert it into the tokens table
+func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+// [Insert()] method add the data of a specific token to the tokens table
+func (m TokenModel) Insert(token *Token) error {
+	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, insertTokenQuery, args...)
+	return err
+}
+
+// [insertTokenQuery] add a token, it is also used by [UserModel.Register()] inside its transaction
+const insertTokenQuery = `
+	INSERT INTO tokens (hash, user_id, expiry, scope)
+	VALUES ($1, $2, $3, $4)`
+
+// [DeleteAllForUser()] method delete all the tokens of a specific scope for a specific user
+func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
+	query := `
+		DELETE FROM tokens
+		WHERE scope = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, scope, userID)
+	return err
+}
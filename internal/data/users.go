@@ -0,0 +1,333 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+
+	"greenlight.wolfheros.com/internal/validator"
+)
+
+// Define a custom Error for duplicate email address
+var (
+	ErrDuplicateEmail = errors.New("duplicate email")
+)
+
+// [User] struct represent an individual user.
+// Use the [-] (hyphen) struct tag to prevent the [Password] and [Version]
+// fields appearing in any output when encoding it to JSON.
+type User struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name" validate:"required,max=500"`
+	Email     string    `json:"email" validate:"required,email"`
+	Password  password  `json:"-"`
+	Activated bool      `json:"activated"`
+	Version   int       `json:"-"`
+}
+
+// [AnonymousUser] represent a user who is not authenticated
+var AnonymousUser = &User{}
+
+// [IsAnonymous()] method check if a [User] instance is the [AnonymousUser]
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
+// [password] struct hold the plaintext and hashed version of the password.
+// The [plaintext] field is a pointer, so we can tell the difference between a
+// password which is not present at all and an empty string "".
+type password struct {
+	plaintext *string
+	hash      []byte
+}
+
+// [Set()] method calculate the bcrypt hash of a plaintext password,
+// and store both the hash and the plaintext versions in the struct.
+func (p *password) Set(plaintextPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	p.plaintext = &plaintextPassword
+	p.hash = hash
+
+	return nil
+}
+
+// [Matches()] method check whether the plaintext password matches
+// the hashed password stored in the struct.
+func (p *password) Matches(plaintextPassword string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Check the email address
+func ValidateEmail(v *validator.Validator, email string) {
+	v.CheckCode(email != "", "email", "required", "must be provided")
+	v.CheckCode(email == "" || validator.Matches(email, validator.EmailRX), "email", "email", "must be a valid email address")
+}
+
+// Check the plaintext password, bcrypt only use the first 72 bytes of the password,
+// a strong password must be long enough and contain both letters and numbers.
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	if password == "" {
+		v.AddErrorCode("password", "required", "must be provided")
+		return
+	}
+
+	v.CheckCode(len(password) >= 8, "password", "min_length", "must be at least 8 bytes long", "min", 8)
+	v.CheckCode(len(password) <= 72, "password", "max_length", "must not be more than 72 bytes long", "max", 72)
+
+	var hasLetter, hasNumber bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsNumber(r):
+			hasNumber = true
+		}
+	}
+	v.CheckCode(hasLetter && hasNumber, "password", "password_strength", "must contain at least one letter and one number")
+}
+
+// [ValidateNewUser()] check the user and the plaintext password before the password
+// is hashed, bcrypt reject the passwords over 72 bytes and hashing is expensive
+func ValidateNewUser(v *validator.Validator, user *User, plaintextPassword string) {
+	validator.Struct(v, user)
+	ValidatePasswordPlaintext(v, plaintextPassword)
+}
+
+func ValidateUser(v *validator.Validator, user *User) {
+	// The name and email are checked by the [validate] tags of the [User] struct
+	validator.Struct(v, user)
+
+	// If the plaintext password is not nil, check it
+	if user.Password.plaintext != nil {
+		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+	}
+
+	// The password hash should never be nil, if it is, it is a logic error
+	// in our codebase (probably forget to set a password for the user)
+	if user.Password.hash == nil {
+		panic("missing password hash for user")
+	}
+}
+
+// Define user models struct to store DB config
+type UserModel struct {
+	DB *sql.DB
+}
+
+// [Insert()] method create a new record in the users table, the [id], [created_at]
+// and [version] are generated by the database. If the email address is already
+// used, return [ErrDuplicateEmail] error.
+// The [permissions] are granted in the same transaction, so a user is never
+// created without them if the grant fail.
+func (m UserModel) Insert(user *User, permissions ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op after the commit
+	defer tx.Rollback()
+
+	err = insertUserTx(ctx, tx, user, permissions)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// [Register()] method insert a new user like [Insert()], and create its activation token
+// in the same transaction. If any step fail nothing is stored, so the email address
+// is not taken by a user who could never be activated.
+func (m UserModel) Register(user *User, activationTTL time.Duration, permissions ...string) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = insertUserTx(ctx, tx, user, permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken(user.ID, activationTTL, ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, insertTokenQuery, token.Hash, token.UserID, token.Expiry, token.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, tx.Commit()
+}
+
+// [insertUserTx()] insert the user and grant the permissions in the transaction
+func insertUserTx(ctx context.Context, tx *sql.Tx, user *User, permissions []string) error {
+	query := `
+		INSERT INTO users (name, email, password_hash, activated)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version`
+
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	if len(permissions) > 0 {
+		_, err = tx.ExecContext(ctx, addPermissionsQuery, user.ID, pq.Array(permissions))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// [GetByEmail()] method retrieve the user details by the email address,
+// the email column is [citext] type, so the match is case-insensitive.
+func (m UserModel) GetByEmail(email string) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, version
+		FROM users
+		WHERE email = $1`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// [Update()] method update the details of a specific user, check the [version]
+// to prevent race conditions like [MovieModel.Update()].
+func (m UserModel) Update(user *User) error {
+	query := `
+		UPDATE users
+		SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version`
+
+	args := []any{
+		user.Name,
+		user.Email,
+		user.Password.hash,
+		user.Activated,
+		user.ID,
+		user.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// [GetForToken()] method retrieve the user associated with a token, which has
+// the given scope and hasn't expired. The plaintext token is hashed before querying,
+// the database only store the hash.
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := hashToken(tokenPlaintext)
+
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		FROM users
+		INNER JOIN tokens
+		ON users.id = tokens.user_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3`
+
+	// [tokenHash] is an array, convert it to a slice by [:]
+	args := []any{tokenHash[:], tokenScope, time.Now()}
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
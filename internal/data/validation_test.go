@@ -0,0 +1,35 @@
+package data
+
+import (
+	"testing"
+
+	"greenlight.wolfheros.com/internal/i18n"
+	"greenlight.wolfheros.com/internal/validator"
+)
+
+// The English messages are written at the call sites, the English catalog must
+// give the same message for the code, so each code has one meaning in every language
+func TestValidationMessagesMatchCatalog(t *testing.T) {
+	v := validator.New()
+
+	ValidateFilters(v, Filters{Page: 0, PageSize: 0, Sort: "rating", SortSafelist: []string{"id"}})
+	ValidateFilters(v, Filters{Page: 10_000_001, PageSize: 101, Sort: "id", SortSafelist: []string{"id"}})
+	ValidateMovie(v, &Movie{Year: 1700, Genres: Genres{"drama", "drama", ""}})
+	ValidateEmail(v, "")
+	ValidateEmail(v, "not-an-email")
+	ValidatePasswordPlaintext(v, "")
+	ValidatePasswordPlaintext(v, "short")
+	ValidatePasswordPlaintext(v, "onlyletters")
+	ValidateTokenPlaintext(v, "")
+	ValidateTokenPlaintext(v, "tooshort")
+
+	if len(v.FieldErrors) == 0 {
+		t.Fatal("want validation errors")
+	}
+
+	for _, e := range v.FieldErrors {
+		if got := i18n.Message(i18n.DefaultLanguage, e.Code, e.Params, ""); got != e.Message {
+			t.Errorf("%s %s: want catalog message %q; got %q", e.Path, e.Code, e.Message, got)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package i18n
+
+// [en] is the English catalog, the messages are the same as the ones written at the
+// call sites, it is only used for the errors which doesn't have a message
+var en = map[string]string{
+	// Validation errors, the codes of [validator.FieldError]
+	"required":          "must be provided",
+	"min_length":        "must be at least {min} bytes long",
+	"max_length":        "must not be more than {max} bytes long",
+	"min_items":         "must contain at least {min} values",
+	"max_items":         "must not contain more than {max} values",
+	"min":               "must be at least {min}",
+	"max":               "must not be greater than {max}",
+	"between":           "must be between {min} and {max}",
+	"unique":            "must not contain duplicate values",
+	"email":             "must be a valid email address",
+	"password_strength": "must contain at least one letter and one number",
+	"length":            "must be {length} bytes long",
+	"permitted_value":   "must be one of {values}",
+	"integer":           "must be an integer value",
+	"duplicate_email":   "a user with this email address already exists",
+	"invalid_token":     "invalid or expired activation token",
+	"invalid_cursor":    "invalid or expired cursor",
+	"exclusive":         "must not be used together with {with}",
+	"page_min":          "must be greater than zero",
+	"page_max":          "must be a maximum of 10 million",
+	"page_size_min":     "must be greater than zero",
+	"page_size_max":     "must be a maximum of {max}",
+	"invalid_sort":      "invalid sort value",
+
+	// Error responses, the codes of the helpers in errors.go
+	"failed_validation":            "one or more fields failed validation",
+	"server_error":                 "the server encountered a problem and could not proces your request",
+	"not_found":                    "the requested resource could not be found",
+	"method_not_allowed":           "the {method} method is not supported for this resource",
+	"edit_conflict":                "unable to update the record due to an edit conflict, please try again",
+	"precondition_failed":          "the resource has been changed since it was last fetched, please fetch it again",
+	"not_permitted":                "your user account doesn't have the necessary permissions to access this resource",
+	"invalid_credentials":          "invalid authentication credentials",
+	"invalid_authentication_token": "invalid or missing authentication token",
+	"authentication_required":      "you must be authenticated to access this resource",
+	"inactive_account":             "your user account must be activated to access this resource",
+	"rate_limit_exceeded":          "rate limit exceeded",
+
+	// Request body errors, the codes of the errors returned by [readJSON()]
+	"malformed_json":         "body contain badly-formed JSON (at charact {offset})",
+	"incomplete_json":        "body contain badly-formed JSON",
+	"incorrect_json_type":    "body contains incorrect JSON type for filed \"{field}\"",
+	"incorrect_json_type_at": "body contains incorrect JSON type (at character {offset})",
+	"empty_body":             "body must not be empty",
+	"unknown_field":          "body contains unknown key \"{field}\"",
+	"body_too_large":         "body must not be larger than {limit} bytes",
+	"multiple_json_values":   "body must only contain a single JSON value per request",
+	"invalid_runtime":        "invalid runtime format",
+	"invalid_body":           "body could not be decoded",
+}
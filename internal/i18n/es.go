@@ -0,0 +1,55 @@
+package i18n
+
+// [es] is the Spanish catalog
+var es = map[string]string{
+	// Validation errors, the codes of [validator.FieldError]
+	"required":          "es obligatorio",
+	"min_length":        "debe tener al menos {min} bytes",
+	"max_length":        "no debe tener más de {max} bytes",
+	"min_items":         "debe contener al menos {min} valores",
+	"max_items":         "no debe contener más de {max} valores",
+	"min":               "debe ser al menos {min}",
+	"max":               "no debe ser mayor que {max}",
+	"between":           "debe estar entre {min} y {max}",
+	"unique":            "no debe contener valores duplicados",
+	"email":             "debe ser una dirección de correo electrónico válida",
+	"password_strength": "debe contener al menos una letra y un número",
+	"length":            "debe tener {length} bytes",
+	"permitted_value":   "debe ser uno de {values}",
+	"integer":           "debe ser un número entero",
+	"duplicate_email":   "ya existe un usuario con esta dirección de correo electrónico",
+	"invalid_token":     "token de activación no válido o caducado",
+	"invalid_cursor":    "cursor no válido o caducado",
+	"exclusive":         "no se debe usar junto con {with}",
+	"page_min":          "debe ser mayor que cero",
+	"page_max":          "debe ser como máximo 10 millones",
+	"page_size_min":     "debe ser mayor que cero",
+	"page_size_max":     "debe ser como máximo {max}",
+	"invalid_sort":      "valor de ordenación no válido",
+
+	// Error responses, the codes of the helpers in errors.go
+	"failed_validation":            "uno o más campos no superaron la validación",
+	"server_error":                 "el servidor encontró un problema y no pudo procesar su solicitud",
+	"not_found":                    "no se encontró el recurso solicitado",
+	"method_not_allowed":           "el método {method} no está permitido para este recurso",
+	"edit_conflict":                "no se pudo actualizar el registro debido a un conflicto de edición, inténtelo de nuevo",
+	"precondition_failed":          "el recurso ha cambiado desde la última vez que se obtuvo, vuelva a obtenerlo",
+	"not_permitted":                "su cuenta de usuario no tiene los permisos necesarios para acceder a este recurso",
+	"invalid_credentials":          "credenciales de autenticación no válidas",
+	"invalid_authentication_token": "token de autenticación no válido o ausente",
+	"authentication_required":      "debe autenticarse para acceder a este recurso",
+	"inactive_account":             "su cuenta de usuario debe estar activada para acceder a este recurso",
+	"rate_limit_exceeded":          "se superó el límite de solicitudes",
+
+	// Request body errors, the codes of the errors returned by [readJSON()]
+	"malformed_json":         "el cuerpo contiene JSON mal formado (en el carácter {offset})",
+	"incomplete_json":        "el cuerpo contiene JSON mal formado",
+	"incorrect_json_type":    "el cuerpo contiene un tipo JSON incorrecto para el campo \"{field}\"",
+	"incorrect_json_type_at": "el cuerpo contiene un tipo JSON incorrecto (en el carácter {offset})",
+	"empty_body":             "el cuerpo no debe estar vacío",
+	"unknown_field":          "el cuerpo contiene la clave desconocida \"{field}\"",
+	"body_too_large":         "el cuerpo no debe superar los {limit} bytes",
+	"multiple_json_values":   "el cuerpo solo debe contener un único valor JSON por solicitud",
+	"invalid_runtime":        "formato de duración no válido",
+	"invalid_body":           "no se pudo decodificar el cuerpo",
+}
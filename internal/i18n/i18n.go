@@ -0,0 +1,150 @@
+package i18n
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// [DefaultLanguage] is used when the client doesn't ask for a supported language.
+// The English messages are also written at the call sites, so they are the fallback
+// of any code which is not in the catalog of the requested language.
+const DefaultLanguage = "en"
+
+// [catalogs] hold the messages of each language keyed by the error code, the messages
+// can refer to the params of the error by name, such as "must be at least {min} bytes long"
+var catalogs = map[string]map[string]string{
+	"en": en,
+	"es": es,
+}
+
+// [Languages()] return the supported languages, sorted
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+
+	slices.Sort(languages)
+
+	return languages
+}
+
+// [Negotiate()] choose the language from the [Accept-Language] header value, such as
+// "es-MX, es;q=0.9, en;q=0.8". The language ranges are tried by quality, a range like
+// "es-MX" also match the "es" language. Return [DefaultLanguage] if nothing match.
+func Negotiate(acceptLanguage string) string {
+	type languageRange struct {
+		tag     string
+		quality float64
+	}
+
+	var ranges []languageRange
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+
+		// A quality of 0 mean "not acceptable"
+		if quality > 0 {
+			ranges = append(ranges, languageRange{tag, quality})
+		}
+	}
+
+	// Keep the order of the header for the same quality
+	slices.SortStableFunc(ranges, func(a, b languageRange) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return DefaultLanguage
+		}
+
+		language, _, _ := strings.Cut(r.tag, "-")
+		if _, ok := catalogs[language]; ok {
+			return language
+		}
+	}
+
+	return DefaultLanguage
+}
+
+// [Message()] return the message of the code in the language, with the params interpolated.
+// In the [DefaultLanguage] the [fallback] (the English message of the call site) is
+// returned as it is, the other languages fall back to it when the code is not in their
+// catalog. The English catalog is the last resort, when there is no fallback.
+func Message(language, code string, params map[string]any, fallback string) string {
+	if language == DefaultLanguage && fallback != "" {
+		return fallback
+	}
+
+	if message, ok := catalogs[language][code]; ok {
+		return interpolate(message, params)
+	}
+
+	if fallback != "" {
+		return fallback
+	}
+
+	if message, ok := catalogs[DefaultLanguage][code]; ok {
+		return interpolate(message, params)
+	}
+
+	return code
+}
+
+// [interpolate()] replace the {name} placeholders by the param values,
+// an unknown placeholder is left as it is
+func interpolate(message string, params map[string]any) string {
+	if len(params) == 0 {
+		return message
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", formatParam(value))
+	}
+
+	return strings.NewReplacer(pairs...).Replace(message)
+}
+
+// [formatParam()] format the param value, the floats without exponent
+// or trailing zeros, and the lists separated by comma
+func formatParam(value any) string {
+	switch value := value.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32)
+	case []string:
+		return strings.Join(value, ", ")
+	default:
+		return fmt.Sprint(value)
+	}
+}
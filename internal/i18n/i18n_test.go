@@ -0,0 +1,90 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "en"},
+		{"es", "es"},
+		{"es-MX", "es"},
+		{"ES-mx, en;q=0.5", "es"},
+		{"fr, es;q=0.8, en;q=0.9", "en"},
+		{"fr, es;q=0.8", "es"},
+		{"es;q=0, en", "en"},
+		{"fr", "en"},
+		{"*", "en"},
+		{"en;q=0.5, es;q=0.5", "en"},
+		{"es;q=bad, en;q=0.1", "en"},
+	}
+
+	for _, tt := range tests {
+		if got := Negotiate(tt.acceptLanguage); got != tt.want {
+			t.Errorf("%q: want %q; got %q", tt.acceptLanguage, tt.want, got)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		params   map[string]any
+		fallback string
+		want     string
+	}{
+		{"Default language use fallback", "en", "min", map[string]any{"min": 1}, "must be greater than zero", "must be greater than zero"},
+		{"Translated", "es", "required", nil, "must be provided", "es obligatorio"},
+		{"Integer param", "es", "max_length", map[string]any{"max": 500}, "", "no debe tener más de 500 bytes"},
+		{"Float params", "es", "between", map[string]any{"min": 1888.0, "max": 1e7}, "", "debe estar entre 1888 y 10000000"},
+		{"List param", "es", "permitted_value", map[string]any{"values": []string{"mins", "integer"}}, "", "debe ser uno de mins, integer"},
+		{"Unknown code use fallback", "es", "no_such_code", nil, "something went wrong", "something went wrong"},
+		{"English catalog without fallback", "en", "unique", nil, "", "must not contain duplicate values"},
+		{"Unknown code without fallback", "es", "no_such_code", nil, "", "no_such_code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Message(tt.language, tt.code, tt.params, tt.fallback); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// Every language must translate the same codes as English, with the same placeholders
+func TestCatalogs(t *testing.T) {
+	placeholderRX := regexp.MustCompile(`\{\w+\}`)
+
+	for _, language := range Languages() {
+		catalog := catalogs[language]
+
+		if len(catalog) != len(en) {
+			t.Errorf("%s: want %d messages; got %d", language, len(en), len(catalog))
+		}
+
+		for code, message := range en {
+			translated, ok := catalog[code]
+			if !ok {
+				t.Errorf("%s: missing %q", language, code)
+				continue
+			}
+
+			want := placeholderRX.FindAllString(message, -1)
+			got := placeholderRX.FindAllString(translated, -1)
+			slices.Sort(want)
+			slices.Sort(got)
+
+			if !slices.Equal(want, got) {
+				t.Errorf("%s: %q placeholders: want %v; got %v", language, code, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"embed"
+	"fmt"
+	"html/template"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	textTemplate "text/template"
+	"time"
+)
+
+// Use the [//go:embed] directive to embed the content of the [./templates]
+// directory into the [templateFS] variable, the templates are part of the binary.
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// [Sender] is implemented by [Mailer], the handlers only depend on it,
+// so the tests can record the emails instead of sending them
+type Sender interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+// [Timeout] is the deadline of a single attempt to send an email,
+// including the dial, so an unreachable SMTP server can't block forever
+const Timeout = 10 * time.Second
+
+// [Mailer] struct hold the SMTP server settings and the sender information
+// (name and address), such as "Greenlight <no-reply@greenlight.wolfheros.com>"
+type Mailer struct {
+	host   string
+	addr   string
+	auth   smtp.Auth
+	sender string
+	from   string
+}
+
+// [New()] create a [Mailer] instance with the given SMTP server settings.
+// If no username is provided, the mail will be sent without authentication.
+func New(host string, port int, username, password, sender string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	// The SMTP envelope only accept the bare address, without the name
+	from := sender
+	if address, err := mail.ParseAddress(sender); err == nil {
+		from = address.Address
+	}
+
+	return Mailer{
+		host:   host,
+		addr:   host + ":" + strconv.Itoa(port),
+		auth:   auth,
+		sender: sender,
+		from:   from,
+	}
+}
+
+// [Send()] method render the [subject], [plainBody] and [htmlBody] templates
+// from the template file, and send them as a multipart email to the recipient.
+func (m Mailer) Send(recipient, templateFile string, data any) error {
+	// The subject and plain-text body use [text/template], the HTML body use
+	// [html/template] which escape the dynamic data automatically.
+	tmpl, err := textTemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return err
+	}
+
+	htmlTmpl, err := template.New("").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return err
+	}
+
+	msg, err := m.message(recipient, subject.String(), plainBody.Bytes(), htmlBody.Bytes())
+	if err != nil {
+		return err
+	}
+
+	// Try sending the email up to three times before giving up,
+	// sleep 500 milliseconds between each attempt.
+	for i := 1; i <= 3; i++ {
+		err = m.sendMail(recipient, msg)
+		if err == nil {
+			return nil
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return err
+}
+
+// [sendMail()] do the same as [smtp.SendMail()], but the connection has a [Timeout] deadline
+func (m Mailer) sendMail(recipient string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", m.addr, Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(Timeout))
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Use TLS if the server support it, like [smtp.SendMail()] does
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: m.host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.auth != nil {
+		err = client.Auth(m.auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(m.from)
+	if err != nil {
+		return err
+	}
+
+	err = client.Rcpt(recipient)
+	if err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// [message()] build the raw email message with a [multipart/alternative] body
+func (m Mailer) message(recipient, subject string, plainBody, htmlBody []byte) ([]byte, error) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	parts := []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", plainBody},
+		{"text/html; charset=UTF-8", htmlBody},
+	}
+
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = w.Write(part.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(bytes.Buffer)
+	fmt.Fprintf(msg, "From: %s\r\n", m.sender)
+	fmt.Fprintf(msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// [Collector] is implemented by every metric which can be exported,
+// [Write()] write the metric family in the Prometheus text exposition format.
+type Collector interface {
+	Write(w io.Writer) error
+}
+
+// [Registry] hold the collectors which are exported by its [Handler()]
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// [NewRegistry()] create an empty [Registry]
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// [Register()] add the collectors to the registry, they are exported
+// in the same order as they are registered
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// [Write()] write all the registered metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	for _, c := range collectors {
+		err := c.Write(bw)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// [Handler()] return a [http.Handler] which serve the registered metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		err := r.Write(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// [DefaultBuckets] are the upper bounds (in seconds) of the histogram buckets,
+// from 5 milliseconds to 10 seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// [HistogramVec] is a histogram partitioned by the label values,
+// each combination of label values is a separate series
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+// [histogram] hold the cumulative bucket counters of a single series
+type histogram struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// [NewHistogramVec()] create a [HistogramVec], the buckets must be sorted in increasing order
+func NewHistogramVec(name, help string, labels []string, buckets []float64) *HistogramVec {
+	return &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+}
+
+// [Observe()] add a single observation to the series of the label values,
+// the number of label values must match the number of labels
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expect %d label values, got %d", h.name, len(h.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, found := h.series[key]
+	if !found {
+		s = &histogram{
+			labelValues: slices.Clone(labelValues),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			s.counts[i]++
+		}
+	}
+
+	s.count++
+	s.sum += value
+}
+
+// [Write()] write the [_bucket], [_sum] and [_count] samples of every series
+func (h *HistogramVec) Write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, escapeHelp(h.help), h.name)
+	if err != nil {
+		return err
+	}
+
+	// Sort the series, so the output is stable between scrapes
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		labels := formatLabels(h.labels, s.labelValues)
+
+		for i, upperBound := range h.buckets {
+			_, err = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(labels, "le", formatFloat(upperBound)), s.counts[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, withLabel(labels, "le", "+Inf"), s.count,
+			h.name, labels, formatFloat(s.sum),
+			h.name, labels, s.count)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// [ValueFunc] is a metric without labels whose value is read from a function
+// each time the metrics are scraped, such as the database pool statistics
+type ValueFunc struct {
+	name       string
+	help       string
+	metricType string
+	fn         func() float64
+}
+
+// [NewGaugeFunc()] create a gauge, the value can go up and down
+func NewGaugeFunc(name, help string, fn func() float64) *ValueFunc {
+	return &ValueFunc{name: name, help: help, metricType: "gauge", fn: fn}
+}
+
+// [NewCounterFunc()] create a counter, the value must only increase
+func NewCounterFunc(name, help string, fn func() float64) *ValueFunc {
+	return &ValueFunc{name: name, help: help, metricType: "counter", fn: fn}
+}
+
+func (v *ValueFunc) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+		v.name, escapeHelp(v.help), v.name, v.metricType, v.name, formatFloat(v.fn()))
+	return err
+}
+
+// [formatLabels()] return the label set such as {method="GET",status="2xx"}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i := range names {
+		pairs[i] = names[i] + `="` + escapeLabelValue(values[i]) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// [withLabel()] append one more label to a formatted label set
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabelValue(value) + `"`
+
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+
+	return strings.TrimSuffix(labels, "}") + "," + pair + "}"
+}
+
+// The label values must escape backslash, double-quote and line feed
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+// The help text only escape backslash and line feed
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHistogramVec(t *testing.T) {
+	h := NewHistogramVec("request_duration_seconds", "Request duration.", []string{"method"}, []float64{.1, 1})
+
+	h.Observe(0.05, "GET")
+	h.Observe(0.1, "GET")
+	h.Observe(0.5, "GET")
+	h.Observe(5, "GET")
+	h.Observe(2, "POST")
+
+	var sb strings.Builder
+	err := h.Write(&sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The buckets are cumulative, an observation equal to the upper bound
+	// is in that bucket, and the series are sorted by the label values
+	want := `# HELP request_duration_seconds Request duration.
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{method="GET",le="0.1"} 2
+request_duration_seconds_bucket{method="GET",le="1"} 3
+request_duration_seconds_bucket{method="GET",le="+Inf"} 4
+request_duration_seconds_sum{method="GET"} 5.65
+request_duration_seconds_count{method="GET"} 4
+request_duration_seconds_bucket{method="POST",le="0.1"} 0
+request_duration_seconds_bucket{method="POST",le="1"} 0
+request_duration_seconds_bucket{method="POST",le="+Inf"} 1
+request_duration_seconds_sum{method="POST"} 2
+request_duration_seconds_count{method="POST"} 1
+`
+
+	if got := sb.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestHistogramVecWrongLabelCount(t *testing.T) {
+	h := NewHistogramVec("request_duration_seconds", "Request duration.", []string{"method", "status"}, DefaultBuckets)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic for a wrong number of label values")
+		}
+	}()
+
+	h.Observe(1, "GET")
+}
+
+func TestEscaping(t *testing.T) {
+	h := NewHistogramVec("escaped", "Help with \\ and\nnew line.", []string{"path"}, []float64{1})
+	h.Observe(1, "a\\b\"c\nd")
+
+	var sb strings.Builder
+	err := h.Write(&sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sb.String()
+
+	for _, want := range []string{
+		// The help text escape the backslash and line feed, but not the double-quote
+		"# HELP escaped Help with \\\\ and\\nnew line.\n",
+		// The label values escape the backslash, double-quote and line feed
+		`escaped_bucket{path="a\\b\"c\nd",le="1"} 1`,
+		`escaped_count{path="a\\b\"c\nd"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want output to contain %q; got:\n%s", want, got)
+		}
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want string
+	}{
+		{0, "0"},
+		{0.005, "0.005"},
+		{2.5, "2.5"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := formatFloat(tt.f); got != tt.want {
+			t.Errorf("%v: want %q; got %q", tt.f, tt.want, got)
+		}
+	}
+}
+
+func TestRegistryHandler(t *testing.T) {
+	registry := NewRegistry()
+
+	open := 3.0
+	registry.Register(
+		NewGaugeFunc("db_open_connections", "Number of open connections.", func() float64 { return open }),
+		NewCounterFunc("db_wait_count_total", "Total number of connections waited for.", func() float64 { return 7 }),
+	)
+
+	// The functions are called on each scrape
+	open = 4
+
+	rr := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d; got %d", http.StatusOK, rr.Code)
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("want the text exposition Content-Type; got %q", ct)
+	}
+
+	// The metrics are written in the order they are registered
+	want := `# HELP db_open_connections Number of open connections.
+# TYPE db_open_connections gauge
+db_open_connections 4
+# HELP db_wait_count_total Total number of connections waited for.
+# TYPE db_wait_count_total counter
+db_wait_count_total 7
+`
+
+	if got := rr.Body.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
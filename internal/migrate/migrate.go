@@ -0,0 +1,422 @@
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// [lockID] is the key of the PostgreSQL advisory lock, it make sure only one
+// migration runner can change the schema at the same time
+const lockID = 7_206_281_134
+
+var (
+	// [ErrDirty] is returned when a previous migration failed halfway,
+	// the schema has to be fixed by hand and the version set with [Force()]
+	ErrDirty = errors.New("database is dirty, fix the schema and force the version")
+
+	// [ErrNoChange] is returned when there is no migration to apply
+	ErrNoChange = errors.New("no change")
+
+	// [ErrUnknownVersion] is returned when a version doesn't match any migration file
+	ErrUnknownVersion = errors.New("unknown migration version")
+
+	// [ErrDuplicateVersion] is returned when several migration files have the same version
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+
+	// [ErrMissingVersion] is returned when there is a gap between the migration versions
+	ErrMissingVersion = errors.New("missing migration version")
+)
+
+// [fileRX] match the migration file names, such as "000001_create_movies_table.up.sql"
+var fileRX = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// [migration] hold the up and down SQL of a single version
+type migration struct {
+	version int64
+	name    string
+	up      string
+	down    string
+}
+
+// [Migrator] apply the migrations to the database, the current version is stored
+// in the [schema_migrations] table, in the same format as the golang-migrate tool,
+// so the databases migrated by that tool can be taken over.
+type Migrator struct {
+	db         *sql.DB
+	logger     *slog.Logger
+	migrations []migration
+}
+
+// [New()] read all the migration files from [fsys], and sort them by version
+func New(db *sql.DB, fsys fs.FS, logger *slog.Logger) (*Migrator, error) {
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, logger: logger, migrations: migrations}, nil
+}
+
+// [readMigrations()] parse the migration file names of [fsys], and return the migrations
+// sorted by version. The versions must be unique and follow each other without a gap,
+// a missing or renamed file is found before anything is applied to the database.
+func readMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*migration)
+
+	for _, entry := range entries {
+		matches := fileRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, found := byVersion[version]
+		if !found {
+			m = &migration{version: version, name: matches[2]}
+			byVersion[version] = m
+		}
+
+		// The up and down files of a version must have the same name,
+		// and there must be only one of each, such as "1_a.up.sql" and "000001_b.up.sql"
+		if m.name != matches[2] {
+			return nil, fmt.Errorf("%w: %d (%s and %s)", ErrDuplicateVersion, version, m.name, matches[2])
+		}
+
+		target := &m.down
+		if matches[3] == "up" {
+			target = &m.up
+		}
+
+		if *target != "" {
+			return nil, fmt.Errorf("%w: %d (%s)", ErrDuplicateVersion, version, entry.Name())
+		}
+		*target = string(content)
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+
+	slices.SortFunc(migrations, func(a, b migration) int {
+		return cmp.Compare(a.version, b.version)
+	})
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version != migrations[i-1].version+1 {
+			return nil, fmt.Errorf("%w: after %d", ErrMissingVersion, migrations[i-1].version)
+		}
+	}
+
+	return migrations, nil
+}
+
+// [Up()] apply the next [n] up migrations, or all of them if [n] is less than 1
+func (m *Migrator) Up(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := m.checkVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		var pending []migration
+		for _, mig := range m.migrations {
+			if mig.version > current {
+				pending = append(pending, mig)
+			}
+		}
+
+		if n > 0 && n < len(pending) {
+			pending = pending[:n]
+		}
+
+		if len(pending) == 0 {
+			return ErrNoChange
+		}
+
+		for _, mig := range pending {
+			err := m.apply(ctx, conn, mig.version, mig.name, "up", mig.up, mig.version)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// [Down()] roll back the last [n] migrations, or all of them if [n] is less than 1
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := m.checkVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		index := m.indexOf(current)
+		if current != -1 && index == -1 {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, current)
+		}
+
+		if index == -1 {
+			return ErrNoChange
+		}
+
+		steps := index + 1
+		if n > 0 && n < steps {
+			steps = n
+		}
+
+		for i := index; i > index-steps; i-- {
+			err := m.apply(ctx, conn, m.migrations[i].version, m.migrations[i].name, "down", m.migrations[i].down, m.previousVersion(i))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// [Goto()] migrate up or down until the schema is at the given version
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	target := m.indexOf(version)
+	if target == -1 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := m.checkVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if current == version {
+			return ErrNoChange
+		}
+
+		index := m.indexOf(current)
+		if current != -1 && index == -1 {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, current)
+		}
+
+		// Walk up to the target version
+		for i := index + 1; i <= target; i++ {
+			err := m.apply(ctx, conn, m.migrations[i].version, m.migrations[i].name, "up", m.migrations[i].up, m.migrations[i].version)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Or walk down to the target version
+		for i := index; i > target; i-- {
+			err := m.apply(ctx, conn, m.migrations[i].version, m.migrations[i].name, "down", m.migrations[i].down, m.previousVersion(i))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// [Version()] return the current version and whether it is dirty,
+// the version is -1 if no migration has been applied
+func (m *Migrator) Version(ctx context.Context) (int64, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		version, dirty, err = m.readVersion(ctx, conn)
+		return err
+	})
+
+	return version, dirty, err
+}
+
+// [Force()] set the version without running any migration and clear the dirty flag,
+// used after fixing a failed migration by hand. -1 mean no migration has been applied
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != -1 && m.indexOf(version) == -1 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		return m.setVersion(ctx, conn, version, false)
+	})
+}
+
+// [withLock()] run [fn] on a single connection which hold the advisory lock,
+// the session-level lock belong to the connection, so it must not go back to the pool
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID)
+	if err != nil {
+		return err
+	}
+
+	// Always release the lock, even if [fn] failed
+	defer func() {
+		_, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+		if err == nil {
+			err = unlockErr
+		}
+	}()
+
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`
+
+	_, err = conn.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// [readVersion()] read the version from the [schema_migrations] table
+func (m *Migrator) readVersion(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+
+	err := conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return -1, false, nil
+		default:
+			return 0, false, err
+		}
+	}
+
+	return version, dirty, nil
+}
+
+// [checkVersion()] return the current version, or [ErrDirty] error if it is dirty
+func (m *Migrator) checkVersion(ctx context.Context, conn *sql.Conn) (int64, error) {
+	version, dirty, err := m.readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	if dirty {
+		return 0, fmt.Errorf("%w (version %d)", ErrDirty, version)
+	}
+
+	return version, nil
+}
+
+// [setVersion()] replace the single row of the [schema_migrations] table
+func (m *Migrator) setVersion(ctx context.Context, conn *sql.Conn, version int64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setVersionTx(ctx, tx, version, dirty)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setVersionTx(ctx context.Context, tx *sql.Tx, version int64, dirty bool) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+
+	if version == -1 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)", version, dirty)
+	return err
+}
+
+// [apply()] run a single migration file in a transaction, and set the version to [newVersion].
+// The version is marked dirty before the migration, so if the process die halfway,
+// the next run refuse to continue until the schema is checked.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, version int64, name, direction, query string, newVersion int64) error {
+	m.logger.Info("applying migration", "version", version, "name", name, "direction", direction)
+
+	err := m.setVersion(ctx, conn, version, true)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A query without arguments is sent as a simple query,
+	// so the file can contain multiple statements
+	if query != "" {
+		_, err = tx.ExecContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("migration %d_%s.%s.sql: %w", version, name, direction, err)
+		}
+	}
+
+	err = setVersionTx(ctx, tx, newVersion, false)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// [indexOf()] return the index of the version in the sorted migrations, or -1
+func (m *Migrator) indexOf(version int64) int {
+	return slices.IndexFunc(m.migrations, func(mig migration) bool {
+		return mig.version == version
+	})
+}
+
+// [previousVersion()] return the version before the migration at index [i], or -1
+func (m *Migrator) previousVersion(i int) int64 {
+	if i == 0 {
+		return -1
+	}
+
+	return m.migrations[i-1].version
+}
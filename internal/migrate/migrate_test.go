@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"greenlight.wolfheros.com/migrations"
+)
+
+func TestReadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_add_index.up.sql":      {Data: []byte("CREATE INDEX")},
+		"000002_add_index.down.sql":    {Data: []byte("DROP INDEX")},
+		"000010_add_column.up.sql":     {Data: []byte("ALTER TABLE")},
+		"000001_create_table.up.sql":   {Data: []byte("CREATE TABLE")},
+		"000001_create_table.down.sql": {Data: []byte("DROP TABLE")},
+		"README.md":                    {Data: []byte("not a migration")},
+		"000003_no_direction.sql":      {Data: []byte("ignored")},
+		"notes/000004_nested.up.sql":   {Data: []byte("ignored")},
+	}
+
+	// Fill the gap between 3 and 9, the versions are sorted as numbers not as strings
+	for _, name := range []string{"3_a", "4_b", "5_c", "6_d", "7_e", "8_f", "9_g"} {
+		fsys[name+".up.sql"] = &fstest.MapFile{}
+	}
+
+	got, err := readMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 10 {
+		t.Fatalf("want 10 migrations; got %d", len(got))
+	}
+
+	for i, m := range got {
+		if m.version != int64(i+1) {
+			t.Errorf("migration %d: want version %d; got %d", i, i+1, m.version)
+		}
+	}
+
+	first := got[0]
+	if first.name != "create_table" || first.up != "CREATE TABLE" || first.down != "DROP TABLE" {
+		t.Errorf("unexpected first migration: %+v", first)
+	}
+
+	// The down file is optional
+	last := got[9]
+	if last.name != "add_column" || last.up != "ALTER TABLE" || last.down != "" {
+		t.Errorf("unexpected last migration: %+v", last)
+	}
+}
+
+func TestReadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  error
+	}{
+		{"Same version with different names", []string{"000001_a.up.sql", "000002_b.up.sql", "2_c.up.sql"}, ErrDuplicateVersion},
+		{"Up and down with different names", []string{"000001_a.up.sql", "000001_b.down.sql"}, ErrDuplicateVersion},
+		{"Same file with different padding", []string{"000001_a.up.sql", "1_a.up.sql"}, ErrDuplicateVersion},
+		{"Gap", []string{"000001_a.up.sql", "000002_b.up.sql", "000004_d.up.sql"}, ErrMissingVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, file := range tt.files {
+				fsys[file] = &fstest.MapFile{Data: []byte("SELECT 1")}
+			}
+
+			_, err := readMigrations(fsys)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestReadMigrationsEmbedded(t *testing.T) {
+	got, err := readMigrations(migrations.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range got {
+		if m.up == "" || m.down == "" {
+			t.Errorf("migration %d_%s: want both up and down files", m.version, m.name)
+		}
+	}
+}
+
+func TestMigratorVersions(t *testing.T) {
+	m := &Migrator{migrations: []migration{{version: 1}, {version: 2}, {version: 3}}}
+
+	if got := m.indexOf(2); got != 1 {
+		t.Errorf("indexOf(2): want 1; got %d", got)
+	}
+	if got := m.indexOf(4); got != -1 {
+		t.Errorf("indexOf(4): want -1; got %d", got)
+	}
+	if got := m.previousVersion(0); got != -1 {
+		t.Errorf("previousVersion(0): want -1; got %d", got)
+	}
+	if got := m.previousVersion(2); got != 2 {
+		t.Errorf("previousVersion(2): want 2; got %d", got)
+	}
+}
@@ -0,0 +1,355 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// [RuleFunc] check a struct field value against the rule, the [param] is the part
+// after "=" in the tag (empty if there is none). It return nil if the check passed,
+// otherwise the error; the [Path] is set by [Struct()], an empty [Code] become the rule name.
+type RuleFunc func(value reflect.Value, param string) *FieldError
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{
+		"required": ruleRequired,
+		"min":      ruleMin,
+		"max":      ruleMax,
+		"between":  ruleBetween,
+		"unique":   ruleUnique,
+		"email":    ruleEmail,
+	}
+)
+
+// [diveRule] apply the rules after it to each item of a slice, or to the fields of a nested struct
+const diveRule = "dive"
+
+// [RegisterRule()] add a rule which can be used in the [validate] struct tags, so the
+// models can add their own domain checks. It panic if the name is empty, contain
+// a "," or "=", or a rule with the same name is already registered.
+func RegisterRule(name string, fn RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	if name == "" || name == diveRule || strings.ContainsAny(name, ",=") {
+		panic("validator: invalid rule name " + strconv.Quote(name))
+	}
+
+	if fn == nil {
+		panic("validator: nil rule " + name)
+	}
+
+	if _, exists := rules[name]; exists {
+		panic("validator: rule registered twice " + name)
+	}
+
+	rules[name] = fn
+}
+
+// [Struct()] check the fields of the struct (or pointer to struct) by their [validate] tags,
+// such as `validate:"required,max=500"`. The rules of a field are checked in order,
+// and stop at the first failure. The errors paths use the JSON names of the fields.
+//
+// The "dive" rule apply the following rules to each item of a slice, such as
+// `validate:"required,unique,dive,required"` (errors at "/genres/2"), or check the
+// fields of a nested struct. An unknown rule or a bad parameter is a bug in the code, so it panic.
+func Struct(v *Validator, x any) {
+	rv := reflect.ValueOf(x)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validator: Struct() called with %T, not a struct", x))
+	}
+
+	validateStruct(v, rv, "")
+}
+
+func validateStruct(v *Validator, rv reflect.Value, prefix string) {
+	rt := rv.Type()
+
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || tag == "" || !field.IsExported() {
+			continue
+		}
+
+		validateValue(v, rv.Field(i), prefix+Pointer(fieldName(field)), strings.Split(tag, ","), rt.Name()+"."+field.Name)
+	}
+}
+
+// [validateValue()] check the value against the rules, [where] is used in the panic messages.
+// A failed rule stop the following rules of the value, but the rules after "dive"
+// are always checked, so the errors of the items are reported too.
+func validateValue(v *Validator, value reflect.Value, path string, tagRules []string, where string) {
+	valueRules, itemRules, hasDive := tagRules, []string(nil), false
+
+	if i := slices.Index(tagRules, diveRule); i >= 0 {
+		valueRules, itemRules, hasDive = tagRules[:i], tagRules[i+1:], true
+	}
+
+	for _, rule := range valueRules {
+		name, param, _ := strings.Cut(rule, "=")
+
+		rulesMu.RLock()
+		fn, exists := rules[name]
+		rulesMu.RUnlock()
+
+		if !exists {
+			panic(fmt.Sprintf("validator: unknown rule %q on %s", name, where))
+		}
+
+		if e := fn(value, param); e != nil {
+			e.Path = path
+			if e.Code == "" {
+				e.Code = name
+			}
+
+			v.Add(*e)
+			break
+		}
+	}
+
+	if hasDive {
+		dive(v, value, path, itemRules, where)
+	}
+}
+
+// [dive()] check each item of the slice, or the fields of the nested struct
+func dive(v *Validator, value reflect.Value, path string, tagRules []string, where string) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range value.Len() {
+			item := value.Index(i)
+			itemPath := path + Pointer(i)
+
+			if len(tagRules) > 0 {
+				validateValue(v, item, itemPath, tagRules, where)
+				continue
+			}
+
+			// Without rules, the struct items are checked by their own tags
+			for item.Kind() == reflect.Pointer && !item.IsNil() {
+				item = item.Elem()
+			}
+			if item.Kind() == reflect.Struct {
+				validateStruct(v, item, itemPath)
+			}
+		}
+	case reflect.Struct:
+		validateStruct(v, value, path)
+	default:
+		panic(fmt.Sprintf("validator: dive rule on a %s on %s", value.Kind(), where))
+	}
+}
+
+// [fieldName()] return the JSON name of the field, or the field name if there is no json tag
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+	if name == "" || name == "-" {
+		return field.Name
+	}
+
+	return name
+}
+
+// [fail()] return the error of a failed check
+func fail(code, message string, params ...any) *FieldError {
+	return &FieldError{Code: code, Message: message, Params: paramsMap(params)}
+}
+
+// [ruleRequired] the value must not be the zero value, the strings,
+// slices and maps must not be empty
+func ruleRequired(value reflect.Value, param string) *FieldError {
+	var ok bool
+
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		ok = value.Len() > 0
+	default:
+		ok = !value.IsZero()
+	}
+
+	if !ok {
+		return fail("required", "must be provided")
+	}
+
+	return nil
+}
+
+// [ruleMin] the strings must be at least [param] bytes long, the slices and maps
+// must contain at least [param] values, the numbers must be at least [param]
+func ruleMin(value reflect.Value, param string) *FieldError {
+	switch value.Kind() {
+	case reflect.String:
+		if n := intParam("min", param); value.Len() < n {
+			return fail("min_length", fmt.Sprintf("must be at least %d bytes long", n), "min", n)
+		}
+	case reflect.Slice, reflect.Map, reflect.Array:
+		if n := intParam("min", param); value.Len() < n {
+			return fail("min_items", fmt.Sprintf("must contain at least %d values", n), "min", n)
+		}
+	default:
+		if n := floatParam("min", param); number(value) < n {
+			return fail("min", fmt.Sprintf("must be at least %s", param), "min", n)
+		}
+	}
+
+	return nil
+}
+
+// [ruleMax] the strings must not be more than [param] bytes long, the slices and maps
+// must not contain more than [param] values, the numbers must not be greater than [param]
+func ruleMax(value reflect.Value, param string) *FieldError {
+	switch value.Kind() {
+	case reflect.String:
+		if n := intParam("max", param); value.Len() > n {
+			return fail("max_length", fmt.Sprintf("must not be more than %d bytes long", n), "max", n)
+		}
+	case reflect.Slice, reflect.Map, reflect.Array:
+		if n := intParam("max", param); value.Len() > n {
+			return fail("max_items", fmt.Sprintf("must not contain more than %d values", n), "max", n)
+		}
+	default:
+		if n := floatParam("max", param); number(value) > n {
+			return fail("max", fmt.Sprintf("must not be greater than %s", param), "max", n)
+		}
+	}
+
+	return nil
+}
+
+// [ruleBetween] the number must be between the two bounds (inclusive), such as
+// "between=1888:now", where "now" is the current year
+func ruleBetween(value reflect.Value, param string) *FieldError {
+	lowParam, highParam, ok := strings.Cut(param, ":")
+	if !ok {
+		panic("validator: between rule need two bounds, such as between=1:10")
+	}
+
+	lowParam, highParam = yearParam(lowParam), yearParam(highParam)
+	low, high := floatParam("between", lowParam), floatParam("between", highParam)
+
+	if n := number(value); n < low || n > high {
+		message := fmt.Sprintf("must be between %s and %s", lowParam, highParam)
+		return fail("between", message, "min", low, "max", high)
+	}
+
+	return nil
+}
+
+// [ruleUnique] the slice must not contain duplicate values. The values which can't
+// be a map key, such as slices, maps or structs with slice fields, are compared
+// by [reflect.DeepEqual()] instead
+func ruleUnique(value reflect.Value, param string) *FieldError {
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		panic("validator: unique rule on a " + value.Kind().String())
+	}
+
+	duplicate := fail("unique", "must not contain duplicate values")
+
+	// [Value.Comparable()] also check the dynamic type of the interface values
+	hashable := true
+	for i := range value.Len() {
+		if !value.Index(i).Comparable() {
+			hashable = false
+			break
+		}
+	}
+
+	if !hashable {
+		for i := range value.Len() {
+			for j := range i {
+				if reflect.DeepEqual(value.Index(i).Interface(), value.Index(j).Interface()) {
+					return duplicate
+				}
+			}
+		}
+
+		return nil
+	}
+
+	seen := make(map[any]bool, value.Len())
+
+	for i := range value.Len() {
+		item := value.Index(i).Interface()
+		if seen[item] {
+			return duplicate
+		}
+		seen[item] = true
+	}
+
+	return nil
+}
+
+// [ruleEmail] the string must be a valid email address, an empty string is
+// left to the [required] rule
+func ruleEmail(value reflect.Value, param string) *FieldError {
+	if value.Kind() != reflect.String {
+		panic("validator: email rule on a " + value.Kind().String())
+	}
+
+	if value.Len() > 0 && !Matches(value.String(), EmailRX) {
+		return fail("email", "must be a valid email address")
+	}
+
+	return nil
+}
+
+// [yearParam()] replace "now" by the current year
+func yearParam(param string) string {
+	if param == "now" {
+		return strconv.Itoa(time.Now().Year())
+	}
+
+	return param
+}
+
+func intParam(rule, param string) int {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("validator: %s rule need an integer parameter, got %q", rule, param))
+	}
+
+	return n
+}
+
+func floatParam(rule, param string) float64 {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validator: %s rule need a number parameter, got %q", rule, param))
+	}
+
+	return n
+}
+
+// [number()] return the value of an integer, unsigned integer or float field
+func number(value reflect.Value) float64 {
+	switch {
+	case value.CanInt():
+		return float64(value.Int())
+	case value.CanUint():
+		return float64(value.Uint())
+	case value.CanFloat():
+		return value.Float()
+	default:
+		panic("validator: number rule on a " + value.Kind().String())
+	}
+}
@@ -0,0 +1,233 @@
+package validator
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMovie struct {
+	Title   string   `json:"title" validate:"required,max=10"`
+	Year    int32    `json:"year,omitempty" validate:"required,between=1888:now"`
+	Runtime int32    `json:"runtime" validate:"min=1"`
+	Genres  []string `json:"genres" validate:"required,max=3,unique"`
+	Email   string   `validate:"email"`
+	Rating  float64  `json:"-" validate:"max=5.5"`
+	Notes   string   `json:"notes"`
+}
+
+func TestStruct(t *testing.T) {
+	thisYear := strconv.Itoa(time.Now().Year())
+
+	valid := testMovie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Rating: 5.5}
+
+	tests := []struct {
+		name   string
+		modify func(m *testMovie)
+		want   map[string]string
+	}{
+		{
+			name:   "Valid",
+			modify: func(m *testMovie) {},
+			want:   map[string]string{},
+		},
+		{
+			name: "Required",
+			modify: func(m *testMovie) {
+				m.Title, m.Year, m.Genres = "", 0, nil
+			},
+			want: map[string]string{
+				"title":  "must be provided",
+				"year":   "must be provided",
+				"genres": "must be provided",
+			},
+		},
+		{
+			name: "Bounds",
+			modify: func(m *testMovie) {
+				m.Title = strings.Repeat("a", 11)
+				m.Year = int32(time.Now().Year() + 1)
+				m.Runtime = -1
+				m.Genres = []string{"a", "b", "c", "d"}
+				m.Rating = 6
+			},
+			want: map[string]string{
+				"title":   "must not be more than 10 bytes long",
+				"year":    "must be between 1888 and " + thisYear,
+				"runtime": "must be at least 1",
+				"genres":  "must not contain more than 3 values",
+				"Rating":  "must not be greater than 5.5",
+			},
+		},
+		{
+			name: "Unique and email",
+			modify: func(m *testMovie) {
+				m.Genres = []string{"a", "a"}
+				m.Email = "not an email"
+			},
+			want: map[string]string{
+				"genres": "must not contain duplicate values",
+				"Email":  "must be a valid email address",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := valid
+			tt.modify(&m)
+
+			v := New()
+			Struct(v, &m)
+
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, v.Errors)
+			}
+		})
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	// The rules are global, remove the test rule so the test can run again (-count)
+	t.Cleanup(func() {
+		rulesMu.Lock()
+		delete(rules, "test_lowercase")
+		rulesMu.Unlock()
+	})
+
+	RegisterRule("test_lowercase", func(value reflect.Value, param string) *FieldError {
+		if value.String() != strings.ToLower(value.String()) {
+			return &FieldError{Message: "must be lowercase"}
+		}
+		return nil
+	})
+
+	var input struct {
+		Code string `json:"code" validate:"required,test_lowercase"`
+	}
+
+	input.Code = "ABC"
+
+	v := New()
+	Struct(v, input)
+
+	want := []FieldError{{Path: "/code", Code: "test_lowercase", Message: "must be lowercase"}}
+	if !reflect.DeepEqual(v.FieldErrors, want) {
+		t.Errorf("want %v; got %v", want, v.FieldErrors)
+	}
+
+	assertPanics(t, "duplicate rule", func() {
+		RegisterRule("test_lowercase", func(reflect.Value, string) *FieldError { return nil })
+	})
+
+	assertPanics(t, "invalid rule name", func() {
+		RegisterRule("a=b", func(reflect.Value, string) *FieldError { return nil })
+	})
+}
+
+func TestStructPaths(t *testing.T) {
+	type cast struct {
+		Name string `json:"name" validate:"required,max=5"`
+	}
+
+	var input struct {
+		Genres []string `json:"genres" validate:"required,unique,dive,required"`
+		Cast   []cast   `json:"cast" validate:"dive"`
+		Lead   *cast    `json:"lead" validate:"dive"`
+	}
+
+	input.Genres = []string{"drama", "", "drama"}
+	input.Cast = []cast{{Name: "Alice"}, {Name: "Dwayne"}}
+	input.Lead = &cast{}
+
+	v := New()
+	Struct(v, &input)
+
+	// The unique rule fail, the items are still checked
+	want := []FieldError{
+		{Path: "/genres", Code: "unique", Message: "must not contain duplicate values"},
+		{Path: "/genres/1", Code: "required", Message: "must be provided"},
+		{Path: "/cast/1/name", Code: "max_length", Message: "must not be more than 5 bytes long", Params: map[string]any{"max": 5}},
+		{Path: "/lead/name", Code: "required", Message: "must be provided"},
+	}
+
+	if !reflect.DeepEqual(v.FieldErrors, want) {
+		t.Errorf("want %v\ngot  %v", want, v.FieldErrors)
+	}
+
+	wantErrors := map[string]string{
+		"genres":       "must not contain duplicate values",
+		"genres[1]":    "must be provided",
+		"cast[1].name": "must not be more than 5 bytes long",
+		"lead.name":    "must be provided",
+	}
+
+	if !reflect.DeepEqual(v.Errors, wantErrors) {
+		t.Errorf("want %v; got %v", wantErrors, v.Errors)
+	}
+}
+
+func TestUniqueUnhashable(t *testing.T) {
+	type cast struct {
+		Name  string
+		Roles []string
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		want  bool
+	}{
+		{"Slices", [][]string{{"a"}, {"b"}}, true},
+		{"Duplicate slices", [][]string{{"a", "b"}, {"c"}, {"a", "b"}}, false},
+		{"Maps", []map[string]int{{"a": 1}, {"a": 2}}, true},
+		{"Duplicate maps", []map[string]int{{"a": 1}, {"a": 1}}, false},
+		{"Structs with slice fields", []cast{{"Moana", []string{"lead"}}, {"Maui", []string{"lead"}}}, true},
+		{"Duplicate structs with slice fields", []cast{{"Moana", []string{"lead"}}, {"Moana", []string{"lead"}}}, false},
+		{"Interfaces", []any{"a", []string{"a"}}, true},
+		{"Duplicate interfaces", []any{1, []string{"a"}, 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ruleUnique(reflect.ValueOf(tt.value), "")
+			if got := err == nil; got != tt.want {
+				t.Errorf("want unique %t; got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStructPanics(t *testing.T) {
+	assertPanics(t, "unknown rule", func() {
+		var input struct {
+			Title string `validate:"no_such_rule"`
+		}
+		Struct(New(), input)
+	})
+
+	assertPanics(t, "bad parameter", func() {
+		var input struct {
+			Title string `validate:"max=many"`
+		}
+		Struct(New(), input)
+	})
+
+	assertPanics(t, "not a struct", func() {
+		Struct(New(), "title")
+	})
+}
+
+func assertPanics(t *testing.T, name string, fn func()) {
+	t.Helper()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: want panic", name)
+		}
+	}()
+
+	fn()
+}
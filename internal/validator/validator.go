@@ -1,8 +1,10 @@
 package validator
 
 import (
+	"fmt"
 	"regexp"
 	"slices"
+	"strings"
 )
 
 // Declare a regular expression for snity checking the format of email address
@@ -11,9 +13,24 @@ var (
 )
 
 
-// Define a new Validator type which contain a map of validation errors
+// [FieldError] is one validation error. The [Path] is a JSON pointer to the field,
+// such as "/title" or "/genres/2", the [Code] is a stable identifier for the machines,
+// such as "required" or "max_length", and the [Params] are the values of the rule,
+// such as {"max": 500}
+type FieldError struct {
+	Path    string         `json:"path"`
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+// Define a new Validator type which contain all the validation errors
+// [FieldErrors] has all of them, several errors can be reported for the same field.
+// [Errors] is the compatibility view, it only keep the first message of each field, keyed
+// by the field name, such as "title", "genres[2]" for array items, "user.name" for nested fields
 type Validator struct{
-	Errors map[string]string
+	Errors      map[string]string
+	FieldErrors []FieldError
 }
 
 // [New()] method is a helper create a instance of [Validator] struct
@@ -25,16 +42,33 @@ func New() *Validator{
 
 // [Validator] struct methods, return ture if it doesn't contain any entries
 func (v *Validator) Valid() bool{
-	return len(v.Errors) == 0
+	return len(v.Errors) == 0 && len(v.FieldErrors) == 0
 }
 
-// Add errors to the map
-func (v *Validator) AddError(key, message string) {
+// [Add()] record the error, the [Path] can be a JSON pointer or a key of the
+// [Errors] map such as "title" or "cast[1].name", it is stored as a JSON pointer
+func (v *Validator) Add(e FieldError) {
+	e.Path = keyPointer(e.Path)
+
+	v.FieldErrors = append(v.FieldErrors, e)
+
+	key := errorsKey(e.Path)
 	if _, exists:= v.Errors[key]; !exists {
-		v.Errors[key] = message
+		v.Errors[key] = e.Message
 	}
 }
 
+// Add errors to the map, without a specific code
+func (v *Validator) AddError(key, message string) {
+	v.AddErrorCode(key, "invalid", message)
+}
+
+// [AddErrorCode()] add an error with the code, the params are key-value
+// pairs like the [slog] attributes, such as AddErrorCode("title", "max_length", msg, "max", 500)
+func (v *Validator) AddErrorCode(key, code, message string, params ...any) {
+	v.Add(FieldError{Path: key, Code: code, Message: message, Params: paramsMap(params)})
+}
+
 // Check adds an error message to the map only if a validation check is not [ok]
 func (v *Validator) Check(ok bool, key, message string){
 	if !ok {
@@ -42,6 +76,117 @@ func (v *Validator) Check(ok bool, key, message string){
 	}
 }
 
+// [CheckCode()] adds an error with the code and params only if a validation check is not [ok]
+func (v *Validator) CheckCode(ok bool, key, code, message string, params ...any) {
+	if !ok {
+		v.AddErrorCode(key, code, message, params...)
+	}
+}
+
+// [Pointer()] build a JSON pointer from the tokens, such as Pointer("genres", 2) is "/genres/2".
+// A single string starting with "/" is already a pointer, it is returned as it is.
+func Pointer(tokens ...any) string {
+	if len(tokens) == 1 {
+		if s, ok := tokens[0].(string); ok && strings.HasPrefix(s, "/") {
+			return s
+		}
+	}
+
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		b.WriteString(pointerEscaper.Replace(fmt.Sprint(token)))
+	}
+
+	return b.String()
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// [keyPointer()] convert the key of the [Errors] map to a JSON pointer, the opposite of
+// [errorsKey()], such as "cast[1].name" to "/cast/1/name". A JSON pointer is returned as it is.
+func keyPointer(key string) string {
+	if strings.HasPrefix(key, "/") {
+		return key
+	}
+
+	var tokens []any
+
+	for _, part := range strings.Split(key, ".") {
+		name, indexes, _ := strings.Cut(part, "[")
+		if name != "" || indexes == "" {
+			tokens = append(tokens, name)
+		}
+
+		// The indexes such as "1]" or "1][2]"
+		for indexes != "" {
+			index, rest, ok := strings.Cut(indexes, "]")
+			if !ok {
+				// Not an index, keep the rest of the key as it is
+				tokens = append(tokens, "["+indexes)
+				break
+			}
+
+			tokens = append(tokens, index)
+			indexes = strings.TrimPrefix(rest, "[")
+		}
+	}
+
+	return Pointer(tokens...)
+}
+
+// [errorsKey()] convert the JSON pointer to the key of the [Errors] map,
+// such as "/genres/2" to "genres[2]" and "/user/name" to "user.name"
+func errorsKey(pointer string) string {
+	var b strings.Builder
+
+	for i, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = pointerUnescaper.Replace(token)
+
+		switch {
+		case i > 0 && isIndex(token):
+			b.WriteString("[" + token + "]")
+		case i > 0:
+			b.WriteString("." + token)
+		default:
+			b.WriteString(token)
+		}
+	}
+
+	return b.String()
+}
+
+func isIndex(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// [paramsMap()] convert the key-value pairs to a map, a key without value is ignored
+func paramsMap(params []any) map[string]any {
+	if len(params) < 2 {
+		return nil
+	}
+
+	m := make(map[string]any, len(params)/2)
+	for i := 0; i+1 < len(params); i += 2 {
+		m[fmt.Sprint(params[i])] = params[i+1]
+	}
+
+	return m
+}
+
 // Generic function which returns true if a specific value in a list of permitted value.
 func PermittedValue[T comparable](value T, permittedValues ...T) bool{
 	return slices.Contains(permittedValues, value)
@@ -0,0 +1,150 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	v := New()
+
+	if !v.Valid() {
+		t.Fatal("a new validator must be valid")
+	}
+
+	v.Check(true, "title", "must be provided")
+	v.Check(false, "year", "must be provided")
+	v.Check(false, "year", "must be greater than 1888")
+	v.AddError("runtime", "must be a positive integer")
+
+	if v.Valid() {
+		t.Fatal("want invalid validator")
+	}
+
+	want := map[string]string{
+		"year":    "must be provided",
+		"runtime": "must be a positive integer",
+	}
+
+	if len(v.Errors) != len(want) {
+		t.Fatalf("want %d errors; got %v", len(want), v.Errors)
+	}
+
+	// Only the first error of each key is kept in the [Errors] map
+	for key, message := range want {
+		if v.Errors[key] != message {
+			t.Errorf("%s: want %q; got %q", key, message, v.Errors[key])
+		}
+	}
+
+	// All of them are kept in [FieldErrors]
+	if len(v.FieldErrors) != 3 {
+		t.Errorf("want 3 field errors; got %v", v.FieldErrors)
+	}
+}
+
+func TestAddErrorCode(t *testing.T) {
+	v := New()
+
+	v.AddErrorCode("title", "max_length", "must not be more than 500 bytes long", "max", 500)
+	v.CheckCode(true, "year", "required", "must be provided")
+	v.AddErrorCode(Pointer("genres", 2), "required", "must be provided")
+	v.AddErrorCode(Pointer("a/b", "c~d"), "invalid", "must be valid")
+	v.AddErrorCode("cast[1].name", "required", "must be provided")
+	v.AddError("matrix[0][2]", "must be valid")
+
+	want := []FieldError{
+		{Path: "/title", Code: "max_length", Message: "must not be more than 500 bytes long", Params: map[string]any{"max": 500}},
+		{Path: "/genres/2", Code: "required", Message: "must be provided"},
+		{Path: "/a~1b/c~0d", Code: "invalid", Message: "must be valid"},
+		{Path: "/cast/1/name", Code: "required", Message: "must be provided"},
+		{Path: "/matrix/0/2", Code: "invalid", Message: "must be valid"},
+	}
+
+	if !reflect.DeepEqual(v.FieldErrors, want) {
+		t.Errorf("want %v\ngot  %v", want, v.FieldErrors)
+	}
+
+	wantErrors := map[string]string{
+		"title":        "must not be more than 500 bytes long",
+		"genres[2]":    "must be provided",
+		"a/b.c~d":      "must be valid",
+		"cast[1].name": "must be provided",
+		"matrix[0][2]": "must be valid",
+	}
+
+	if !reflect.DeepEqual(v.Errors, wantErrors) {
+		t.Errorf("want %v; got %v", wantErrors, v.Errors)
+	}
+}
+
+func TestPermittedValue(t *testing.T) {
+	if !PermittedValue("id", "id", "title", "-id") {
+		t.Error("want id to be permitted")
+	}
+
+	if PermittedValue("rating", "id", "title", "-id") {
+		t.Error("want rating not to be permitted")
+	}
+
+	if PermittedValue(3, 1, 2) {
+		t.Error("want 3 not to be permitted")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"alice@example.com", true},
+		{"bob.smith+tag@mail.example.co.uk", true},
+		{"alice@", false},
+		{"@example.com", false},
+		{"alice example@example.com", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := Matches(tt.email, EmailRX); got != tt.want {
+			t.Errorf("%q: want %t; got %t", tt.email, tt.want, got)
+		}
+	}
+}
+
+func TestUnique(t *testing.T) {
+	if !Unique([]string{"drama", "comedy"}) {
+		t.Error("want unique")
+	}
+
+	if Unique([]string{"drama", "comedy", "drama"}) {
+		t.Error("want not unique")
+	}
+
+	if !Unique([]int{}) {
+		t.Error("want an empty slice to be unique")
+	}
+}
+
+func TestKeyPointer(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"title", "/title"},
+		{"/genres/2", "/genres/2"},
+		{"genres[2]", "/genres/2"},
+		{"cast[1].name", "/cast/1/name"},
+		{"user.address.city", "/user/address/city"},
+		{"matrix[0][2]", "/matrix/0/2"},
+		{"runtime_format", "/runtime_format"},
+		{"a~b", "/a~0b"},
+		{"broken[1", "/broken/[1"},
+	}
+
+	for _, tt := range tests {
+		if got := keyPointer(tt.key); got != tt.want {
+			t.Errorf("%q: want %q; got %q", tt.key, tt.want, got)
+		}
+	}
+}
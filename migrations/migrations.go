@@ -0,0 +1,10 @@
+// Package migrations embed the SQL migration files, so they can be applied
+// by the [migrate] subcommand of the API binary without an outside tool.
+package migrations
+
+import "embed"
+
+// Files contain the [NNNNNN_name.up.sql] and [NNNNNN_name.down.sql] migration files
+//
+//go:embed *.sql
+var Files embed.FS